@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// inputReader reads pex's inputs one after another, like io.MultiReader,
+// decompressing and transcoding each as needed.
+// It records where each input starts, so that the first column can say
+// which input it is showing.
+type inputReader struct {
+	inputs   []input
+	cur      int               // index of the input being read; only Read uses it
+	encoding encoding.Encoding // if set, inputs are transcoded from it to UTF-8
+
+	mu    sync.Mutex
+	off   int   // bytes read so far
+	start []int // start[i] is the offset of the first byte of inputs[i]
+}
+
+type input struct {
+	name    string
+	r       io.Reader
+	sniffed bool // r has been checked for compression
+}
+
+// add appends an input to be read after the existing ones.
+func (r *inputReader) add(name string, in io.Reader) {
+	r.inputs = append(r.inputs, input{name: name, r: in})
+}
+
+func (r *inputReader) Read(p []byte) (int, error) {
+	for r.cur < len(r.inputs) {
+		in := &r.inputs[r.cur]
+		if !in.sniffed {
+			in.sniffed = true
+			rd, err := decompress(in.r)
+			if err != nil {
+				return 0, fmt.Errorf("%s: %w", in.name, err)
+			}
+			if r.encoding != nil {
+				rd = transform.NewReader(rd, unicode.BOMOverride(r.encoding.NewDecoder()))
+			}
+			in.r = rd
+			r.mu.Lock()
+			r.start = append(r.start, r.off)
+			r.mu.Unlock()
+		}
+		n, err := in.r.Read(p)
+		r.mu.Lock()
+		r.off += n
+		r.mu.Unlock()
+		if err == io.EOF {
+			r.cur++
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		if err != nil {
+			err = fmt.Errorf("%s: %w", in.name, err)
+		}
+		return n, err
+	}
+	return 0, io.EOF
+}
+
+// nameAt returns the name of the input containing offset off.
+func (r *inputReader) nameAt(off int) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Empty inputs start where the next one does; prefer the later one.
+	i := sort.Search(len(r.start), func(i int) bool { return r.start[i] > off })
+	return r.inputs[max(0, i-1)].name
+}
+
+// decompress returns a reader that decompresses r,
+// if r begins with the magic bytes of gzip, bzip2 or zlib data.
+// Otherwise, it returns a reader that reads r unchanged.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	// Peek a byte at a time, so that a slow producer's
+	// first short line isn't held up waiting for more.
+	b, _ := br.Peek(1)
+	if len(b) == 0 {
+		return br, nil
+	}
+	switch b[0] {
+	case 0x1f:
+		if b, _ := br.Peek(2); string(b) == "\x1f\x8b" {
+			return gzip.NewReader(br)
+		}
+	case 'B':
+		// "BZh" and a block size from 1 to 9.
+		if !peekPrefix(br, "BZh") {
+			break
+		}
+		if b, _ := br.Peek(4); len(b) == 4 && '1' <= b[3] && b[3] <= '9' {
+			return bzip2.NewReader(br), nil
+		}
+	case 0x78:
+		// A zlib header is 0x78 (deflate, 32K window) and a check byte.
+		// Of the common check bytes, leave out 0x5e: "x^" is plausible text.
+		if b, _ := br.Peek(2); len(b) == 2 && (b[1] == 0x01 || b[1] == 0x9c || b[1] == 0xda) {
+			return zlib.NewReader(br)
+		}
+	}
+	return br, nil
+}
+
+// peekPrefix reports whether br begins with prefix.
+// It peeks a byte at a time, and gives up at the first byte that doesn't match.
+func peekPrefix(br *bufio.Reader, prefix string) bool {
+	for n := 1; n <= len(prefix); n++ {
+		if b, _ := br.Peek(n); len(b) < n || b[n-1] != prefix[n-1] {
+			return false
+		}
+	}
+	return true
+}
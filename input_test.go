@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestInputReader(t *testing.T) {
+	var gz, zl bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	io.WriteString(w, "1\n2\n")
+	w.Close()
+	w2 := zlib.NewWriter(&zl)
+	io.WriteString(w2, "5\n6\n")
+	w2.Close()
+	// printf '3\n4\n' | bzip2
+	bz, err := hex.DecodeString("425a683931415926535912599cc4000001480000100c00200030cc0c7a82717724538509012599cc40")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := new(inputReader)
+	in.add("a.gz", &gz)
+	in.add("empty", strings.NewReader(""))
+	in.add("b.bz2", bytes.NewReader(bz))
+	in.add("c.z", &zl)
+	in.add("d.txt", strings.NewReader("x^7\n"))
+	if got := in.nameAt(0); got != "a.gz" {
+		t.Errorf("before reading: nameAt(0) = %q, want %q", got, "a.gz")
+	}
+	got, err := io.ReadAll(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "1\n2\n3\n4\n5\n6\nx^7\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for _, tt := range []struct {
+		off  int
+		name string
+	}{
+		{0, "a.gz"},
+		{3, "a.gz"},
+		{4, "b.bz2"},
+		{8, "c.z"},
+		{12, "d.txt"},
+		{99, "d.txt"},
+	} {
+		if got := in.nameAt(tt.off); got != tt.name {
+			t.Errorf("nameAt(%d) = %q, want %q", tt.off, got, tt.name)
+		}
+	}
+}
+
+func TestInputReaderCorrupt(t *testing.T) {
+	in := new(inputReader)
+	in.add("bad.gz", strings.NewReader("\x1f\x8bnot really gzip"))
+	_, err := io.ReadAll(in)
+	if err == nil || !strings.HasPrefix(err.Error(), "bad.gz: ") {
+		t.Errorf("got err %v, want one mentioning bad.gz", err)
+	}
+}
+
+func TestInputReaderTranscode(t *testing.T) {
+	in := &inputReader{encoding: charmap.ISO8859_1}
+	in.add("latin1.txt", strings.NewReader("caf\xe9\n"))
+	in.add("utf16.txt", strings.NewReader("\xff\xfea\x00\n\x00"))
+	got, err := io.ReadAll(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "café\na\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDecompressSlowText(t *testing.T) {
+	// Text that starts like bzip2 data shouldn't wait for more than it needs.
+	for _, s := range []string{"B\n", "BZ\n", "Bob\n"} {
+		pr, pw := io.Pipe()
+		go pw.Write([]byte(s)) // and then nothing more, for now
+		done := make(chan io.Reader)
+		go func() {
+			r, _ := decompress(pr)
+			done <- r
+		}()
+		select {
+		case r := <-done:
+			got := make([]byte, len(s))
+			if _, err := io.ReadFull(r, got); err != nil || string(got) != s {
+				t.Errorf("decompress(%q): read %q, %v", s, got, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("decompress(%q) is waiting for more input", s)
+		}
+		pw.Close()
+	}
+}
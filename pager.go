@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
+	"slices"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -30,6 +33,10 @@ type pager struct {
 	shared  *stream.Shared
 	view    *streamview.Model
 	cancel  func()
+	stdout  io.Closer     // read end of cmd's stdout and stderr
+	exited  chan struct{} // closed once cmd.Wait returns
+	fed     chan struct{} // closed once copying to cmd's stdin stops
+	input   *inputReader  // for the first column, which shows pex's inputs
 }
 
 func newCommandPager(r *stream.Shared, command shell.Command) *pager {
@@ -38,59 +45,156 @@ func newCommandPager(r *stream.Shared, command shell.Command) *pager {
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	cmd := exec.CommandContext(ctx, command.Name(), command.Args()...)
-	cmd.Stdin = r.Reader()
-	stdOut, err := cmd.StdoutPipe()
+	// Copy to stdin ourselves, rather than setting cmd.Stdin to r,
+	// because Wait waits for that copying to finish,
+	// which it doesn't if r is idle, even though the process has exited.
+	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		cancel()
 		return newErrorPager(err, command)
 	}
-	cmd.Stderr = cmd.Stdout
-	if err := cmd.Start(); err != nil {
+	// Don't let a misbehaving process keep close from returning.
+	cmd.WaitDelay = time.Second
+	// Use our own pipe rather than StdoutPipe, which Wait closes,
+	// so that we can learn when the process exits
+	// without discarding output that hasn't been read yet.
+	stdout, w, err := os.Pipe()
+	if err != nil {
+		cancel()
+		return newErrorPager(err, command)
+	}
+	cmd.Stdout = w
+	cmd.Stderr = w
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
 		cancel()
+		stdout.Close()
 		return newErrorPager(err, command)
 	}
-	p := newPager(stdOut, command.Raw)
+	p := newPager(stdout, command.Raw, sharedOptions())
 	p.command = command
 	p.cmd = cmd
 	p.cancel = cancel
+	p.stdout = stdout
+	p.exited = make(chan struct{})
+	p.fed = make(chan struct{})
+	p.view.Title = strings.TrimSpace(command.Raw)
+	p.view.Status = runningStyle.Render("running")
+	// Stop copying once the process exits or the pager is closed, even if r is idle.
+	feedCtx, stopFeed := context.WithCancel(ctx)
+	go func() {
+		r.ReaderContext(feedCtx).WriteTo(stdin)
+		stdin.Close()
+		close(p.fed)
+	}()
+	go func() {
+		cmd.Wait()
+		stopFeed()
+		close(p.exited)
+	}()
 	return p
 }
 
+var (
+	runningStyle = lipgloss.NewStyle().Faint(true)
+	successStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("2")) // green
+	failureStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1")) // red
+)
+
+// exitedMsg reports that a pager's process has exited.
+type exitedMsg struct {
+	p *pager
+}
+
+// waitCmd waits for the pager's process to exit.
+func (p *pager) waitCmd() tea.Cmd {
+	if p.exited == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		<-p.exited
+		return exitedMsg{p: p}
+	}
+}
+
+// exitStatus describes how the pager's process exited,
+// such as "exit 1" or "signal: killed".
+// It must be called only after p.exited is closed.
+func (p *pager) exitStatus() (status string, ok bool) {
+	ps := p.cmd.ProcessState
+	switch {
+	case ps == nil:
+		return "wait failed", false
+	case ps.ExitCode() >= 0:
+		return fmt.Sprintf("exit %d", ps.ExitCode()), ps.Success()
+	}
+	return ps.String(), false
+}
+
 func newEmptyPager() *pager {
-	return newPager(strings.NewReader(""), "empty")
+	return newPager(strings.NewReader(""), "empty", sharedOptions())
 }
 
 func newStringPager(s string) *pager {
-	return newPager(strings.NewReader(s), "string: "+s)
+	return newPager(strings.NewReader(s), "string: "+s, sharedOptions())
 }
 
 func newErrorPager(err error, command shell.Command) *pager {
 	s := err.Error()
-	p := newPager(strings.NewReader(s), "error: "+s)
+	p := newPager(strings.NewReader(s), "error: "+s, sharedOptions())
 	p.isErr = true
 	p.command = command
+	p.view.Title = strings.TrimSpace(command.Raw)
+	p.view.Status = failureStyle.Render("error")
 	return p
 }
 
-func newReadPager(r io.Reader) *pager {
-	return newPager(r, fmt.Sprintf("read: %T", r))
+// newInputPager returns a pager for the first column, which reads pex's inputs.
+func newInputPager(in *inputReader) *pager {
+	p := newPager(in, "input", inputOptions())
+	p.input = in
+	p.updateTitle()
+	return p
 }
 
-func newPager(r io.Reader, name string) *pager {
-	shared := stream.NewShared(r)
+func newPager(r io.Reader, name string, opts stream.Options) *pager {
+	shared := stream.NewShared(r, opts)
 	t := streamview.New(shared)
 	t.Name = name
+	t.TabWidth = *flagTabs
+	t.Follow = *flagFollow
 	t.Style = blurredBorderStyle.Copy()
 	t.FocusStyle = focusedBorderStyle.Copy()
 	return &pager{view: &t, shared: shared}
 }
 
 func (p *pager) Update(msg tea.Msg) tea.Cmd {
+	if msg, ok := msg.(exitedMsg); ok && msg.p == p {
+		status, ok := p.exitStatus()
+		if ok {
+			p.view.Status = successStyle.Render(status)
+		} else {
+			p.view.Status = failureStyle.Render(status)
+		}
+	}
 	v, cmd := p.view.Update(msg)
 	p.view = &v
+	p.updateTitle()
 	return cmd
 }
 
+// updateTitle labels an input column with the input at the top of the view.
+func (p *pager) updateTitle() {
+	if p.input == nil {
+		return
+	}
+	// With -encoding, this is an offset into the decoded text,
+	// so for multibyte encodings the title may change a little late.
+	off, _ := p.view.TopOffset()
+	p.view.Title = p.input.nameAt(off)
+}
+
 func (p *pager) Blur() {
 	p.view.Blur()
 }
@@ -100,11 +204,54 @@ func (p *pager) Focus() {
 }
 
 func (p *pager) Init() tea.Cmd {
-	return p.view.Init()
+	return tea.Batch(p.view.Init(), p.waitCmd())
+}
+
+// toggleNULSep switches the column between newline- and NUL-separated records.
+func (p *pager) toggleNULSep() tea.Cmd {
+	if p.view.Sep() == nil {
+		return p.view.SetSep([]byte{0})
+	}
+	return p.view.SetSep(nil)
+}
+
+// linkable reports whether the column shows the output of a pipeline stage,
+// whose lines can be lined up with the other stages'.
+func (p *pager) linkable() bool {
+	return !p.isErr && (p.cmd != nil || p.input != nil)
 }
 
+// toggleANSI switches the column between showing and stripping ANSI colors.
+func (p *pager) toggleANSI() {
+	if p.view.ANSI == streamview.ANSIRender {
+		p.view.ANSI = streamview.ANSIStrip
+	} else {
+		p.view.ANSI = streamview.ANSIRender
+	}
+}
+
+// tabWidths are the tab stop distances that cycleTabWidth cycles through.
+var tabWidths = []int{8, 4, 2}
+
+// cycleTabWidth moves the column's tab stops to the next of tabWidths.
+func (p *pager) cycleTabWidth() {
+	i := slices.Index(tabWidths, p.view.TabWidth)
+	p.view.TabWidth = tabWidths[(i+1)%len(tabWidths)]
+}
+
+// close stops the pager's process, if any, and waits for it to exit.
+// Afterwards, the pager holds no goroutines and no references to upstream streams.
 func (p *pager) close() {
 	if p.cancel != nil {
 		p.cancel()
 	}
+	p.view.Close()
+	if p.exited != nil {
+		<-p.exited
+		<-p.fed
+	}
+	if p.stdout != nil {
+		p.stdout.Close()
+	}
+	p.shared.Close()
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/josharian/pex/shell"
+	"github.com/josharian/pex/stream"
+)
+
+func TestPagerCloseLeak(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not available")
+	}
+	before := runtime.NumGoroutine()
+
+	// An upstream stage that has produced some output, and then gone quiet.
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	upstream := stream.NewShared(pr, stream.Options{})
+	go pw.Write([]byte("hello\n"))
+
+	commands, _, err := shell.Parse("cat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newCommandPager(upstream, commands[0])
+	if p.isErr {
+		t.Fatalf("failed to start pager: %s", p.shared.Buffer().Line(0))
+	}
+	// Start the view reading, as Bubble Tea would.
+	read := make(chan struct{})
+	go func() {
+		runCmd(p.Init())
+		close(read)
+	}()
+	for p.shared.Buffer().NLines() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	p.close()
+	<-read
+	// Only upstream's in-flight read of the idle pipe may remain.
+	// It belongs to upstream, not p.
+	waitGoroutines(t, before+1)
+	pw.Close()
+	<-upstream.Done()
+	waitGoroutines(t, before)
+}
+
+func TestPagerExitStatus(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	upstream := stream.NewShared(strings.NewReader(""), stream.Options{})
+	for _, tt := range []struct {
+		cmd    string
+		status string
+		ok     bool
+	}{
+		{"sh -c 'echo hi'", "exit 0", true},
+		{"sh -c 'exit 3'", "exit 3", false},
+		{"sh -c 'kill -9 $$'", "signal: killed", false},
+	} {
+		commands, _, err := shell.Parse(tt.cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := newCommandPager(upstream, commands[0])
+		if p.isErr {
+			t.Fatalf("%s: failed to start pager: %s", tt.cmd, p.shared.Buffer().Line(0))
+		}
+		// The process exits without anyone reading its output.
+		<-p.exited
+		if status, ok := p.exitStatus(); status != tt.status || ok != tt.ok {
+			t.Errorf("%s: got status %q, %v, want %q, %v", tt.cmd, status, ok, tt.status, tt.ok)
+		}
+		p.close()
+	}
+}
+
+func TestPagerExitIdleUpstream(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	// An upstream stage that has produced a line, and then gone quiet, like tail -f.
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	upstream := stream.NewShared(pr, stream.Options{})
+	go pw.Write([]byte("hello\n"))
+
+	commands, _, err := shell.Parse("sh -c 'read line; echo $line'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newCommandPager(upstream, commands[0])
+	if p.isErr {
+		t.Fatalf("failed to start pager: %s", p.shared.Buffer().Line(0))
+	}
+	select {
+	case <-p.exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("process exited, but the pager still says it's running")
+	}
+	if status, ok := p.exitStatus(); status != "exit 0" || !ok {
+		t.Errorf("got status %q, %v, want %q, true", status, ok, "exit 0")
+	}
+	p.close()
+}
+
+// runCmd runs cmd, and any commands it batches, as Bubble Tea would.
+// It returns once they have all returned.
+func runCmd(cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	batch, ok := cmd().(tea.BatchMsg)
+	if !ok {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, c := range batch {
+		wg.Add(1)
+		go func(c tea.Cmd) {
+			defer wg.Done()
+			runCmd(c)
+		}(c)
+	}
+	wg.Wait()
+}
+
+// waitGoroutines waits for the number of goroutines to drop to n.
+func waitGoroutines(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			t.Fatalf("leaked %d goroutines:\n%s", runtime.NumGoroutine()-n, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
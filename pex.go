@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"flag"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"log/slog"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/cursor"
@@ -17,6 +19,9 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/josharian/pex/shell"
+	"github.com/josharian/pex/stream"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
 )
 
 const (
@@ -33,6 +38,25 @@ type keymap = struct {
 	pageUp      key.Binding
 	down        key.Binding
 	up          key.Binding
+	nulSep      key.Binding
+	timeGutter  key.Binding
+	hex         key.Binding
+	ansi        key.Binding
+	invisibles  key.Binding
+	tabWidth    key.Binding
+	wrap        key.Binding
+	wrapAll     key.Binding
+	scrollLeft  key.Binding
+	scrollRight key.Binding
+	lineNumbers key.Binding
+	numbersAll  key.Binding
+	follow      key.Binding
+	search      key.Binding
+	searchNext  key.Binding
+	searchPrev  key.Binding
+	gotoLine    key.Binding
+	linkScroll  key.Binding
+	diff        key.Binding
 }
 
 var defaultKeymap = keymap{
@@ -45,12 +69,12 @@ var defaultKeymap = keymap{
 		key.WithHelp("shift+tab", "prev"),
 	),
 	top: key.NewBinding(
-		key.WithKeys("ctrl+["),
-		key.WithHelp("ctrl+[", "top"),
+		key.WithKeys("ctrl+home", "alt+<"),
+		key.WithHelp("alt+<", "top"),
 	),
 	bottom: key.NewBinding(
-		key.WithKeys("ctrl+]"),
-		key.WithHelp("ctrl+]", "bottom"),
+		key.WithKeys("ctrl+end", "alt+>"),
+		key.WithHelp("alt+>", "bottom"),
 	),
 	quit: key.NewBinding(
 		key.WithKeys("esc", "ctrl+c"),
@@ -72,6 +96,82 @@ var defaultKeymap = keymap{
 		key.WithKeys("down"),
 		key.WithHelp("↓", "down"),
 	),
+	nulSep: key.NewBinding(
+		key.WithKeys("alt+0"),
+		key.WithHelp("alt+0", "NUL/newline records"),
+	),
+	timeGutter: key.NewBinding(
+		key.WithKeys("alt+t"),
+		key.WithHelp("alt+t", "line times"),
+	),
+	hex: key.NewBinding(
+		key.WithKeys("alt+x"),
+		key.WithHelp("alt+x", "hex/text"),
+	),
+	ansi: key.NewBinding(
+		key.WithKeys("alt+c"),
+		key.WithHelp("alt+c", "colors"),
+	),
+	invisibles: key.NewBinding(
+		key.WithKeys("alt+i"),
+		key.WithHelp("alt+i", "invisibles"),
+	),
+	tabWidth: key.NewBinding(
+		key.WithKeys("alt+s"),
+		key.WithHelp("alt+s", "tab stops"),
+	),
+	wrap: key.NewBinding(
+		key.WithKeys("alt+w"),
+		key.WithHelp("alt+w", "wrap"),
+	),
+	wrapAll: key.NewBinding(
+		key.WithKeys("alt+W"),
+		key.WithHelp("alt+W", "wrap all"),
+	),
+	scrollLeft: key.NewBinding(
+		key.WithKeys("shift+left"),
+		key.WithHelp("shift+←", "scroll left"),
+	),
+	scrollRight: key.NewBinding(
+		key.WithKeys("shift+right"),
+		key.WithHelp("shift+→", "scroll right"),
+	),
+	lineNumbers: key.NewBinding(
+		key.WithKeys("alt+n"),
+		key.WithHelp("alt+n", "line numbers"),
+	),
+	numbersAll: key.NewBinding(
+		key.WithKeys("alt+N"),
+		key.WithHelp("alt+N", "line numbers, all"),
+	),
+	follow: key.NewBinding(
+		key.WithKeys("alt+F"),
+		key.WithHelp("alt+F", "follow"),
+	),
+	search: key.NewBinding(
+		key.WithKeys("alt+/"),
+		key.WithHelp("alt+/", "search"),
+	),
+	searchNext: key.NewBinding(
+		key.WithKeys("ctrl+n"),
+		key.WithHelp("ctrl+n", "next match"),
+	),
+	searchPrev: key.NewBinding(
+		key.WithKeys("ctrl+p"),
+		key.WithHelp("ctrl+p", "previous match"),
+	),
+	gotoLine: key.NewBinding(
+		key.WithKeys("ctrl+g"),
+		key.WithHelp("ctrl+g", "go to line"),
+	),
+	linkScroll: key.NewBinding(
+		key.WithKeys("alt+l"),
+		key.WithHelp("alt+l", "link scroll"),
+	),
+	diff: key.NewBinding(
+		key.WithKeys("alt+D"),
+		key.WithHelp("alt+D", "diff against input"),
+	),
 }
 
 type model struct {
@@ -82,11 +182,16 @@ type model struct {
 	pagers          []*pager
 	bottomTextInput textinput.Model
 	errText         textinput.Model
+	prompt          textinput.Model
+	promptMode      promptMode // which prompt, if any, has the keyboard
 	commands        []shell.Command
 	pipes           []int
 	minPager        int
 	maxPager        int
 	focusedPager    int
+	wrap            bool // whether new columns wrap long lines
+	lineNumbers     bool // whether new columns show line numbers
+	linked          bool // whether visible columns scroll together
 	err             error
 }
 
@@ -97,6 +202,21 @@ func initialBottom() textinput.Model {
 	return ti
 }
 
+// promptMode is what the prompt in place of the help line is asking for.
+type promptMode int
+
+const (
+	promptNone   promptMode = iota
+	promptSearch            // a search query
+	promptGoto              // a line to go to
+)
+
+func initialPrompt() textinput.Model {
+	ti := textinput.New()
+	ti.Blur()
+	return ti
+}
+
 func initialErrText() textinput.Model {
 	ti := textinput.New()
 	ti.Blur()
@@ -106,22 +226,27 @@ func initialErrText() textinput.Model {
 }
 
 func newModel(args []string) (*model, error) {
-	var files []io.Reader
+	in := new(inputReader)
+	if *flagTranscode {
+		in.encoding = inputEncoding
+		if in.encoding == nil {
+			// Still decode UTF-16 with a byte order mark.
+			in.encoding = encoding.Nop
+		}
+	}
 	for _, path := range args {
 		f, err := os.Open(path)
 		if err != nil {
 			return nil, err
 		}
 		// we never close these files; they'll go away when the process ends
-		files = append(files, f)
+		in.add(path, f)
 	}
-
-	var in io.Reader = os.Stdin
-	if len(files) > 0 {
-		in = io.MultiReader(files...)
+	if len(args) == 0 {
+		in.add("stdin", os.Stdin)
 	}
 
-	p0 := newReadPager(in)
+	p0 := newInputPager(in)
 	m := &model{
 		pagers: []*pager{
 			p0,
@@ -131,6 +256,7 @@ func newModel(args []string) (*model, error) {
 		maxPager:        0,
 		bottomTextInput: initialBottom(),
 		errText:         initialErrText(),
+		prompt:          initialPrompt(),
 		help:            help.New(),
 		keymap:          defaultKeymap,
 	}
@@ -154,11 +280,20 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	prevRawShell := m.bottomTextInput.Value()
 
 	var cmds []tea.Cmd
+	// Keys that control columns must not also be typed into the pipeline.
+	inputMsg := msg
+	// The column that linked columns follow, if not the focused one.
+	leader := -1
 
 	switch msg := msg.(type) {
 	case cursor.BlinkMsg:
 		return m, nil
 	case tea.KeyMsg:
+		if m.promptMode != promptNone {
+			cmds = append(cmds, m.updatePrompt(msg))
+			inputMsg = nil
+			break
+		}
 		switch {
 		case key.Matches(msg, m.keymap.quit):
 			return m, tea.Quit
@@ -202,13 +337,113 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				pos = 0
 			}
 			m.bottomTextInput.SetCursor(pos)
+		case key.Matches(msg, m.keymap.nulSep):
+			p := m.pagers[m.focusedPager]
+			cmds = append(cmds, p.toggleNULSep())
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.timeGutter):
+			if !*flagTimestamps {
+				m.SetErr(errors.New("line times require the -timestamps flag"))
+			} else {
+				p := m.pagers[m.focusedPager]
+				p.view.TimeGutter = p.view.TimeGutter.Next()
+			}
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.hex):
+			p := m.pagers[m.focusedPager]
+			cmds = append(cmds, p.view.ToggleHex())
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.ansi):
+			m.pagers[m.focusedPager].toggleANSI()
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.invisibles):
+			p := m.pagers[m.focusedPager]
+			p.view.Invisibles = !p.view.Invisibles
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.tabWidth):
+			m.pagers[m.focusedPager].cycleTabWidth()
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.wrap):
+			p := m.pagers[m.focusedPager]
+			cmds = append(cmds, p.view.ToggleWrap())
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.wrapAll):
+			m.wrap = !m.pagers[m.focusedPager].view.Wrap
+			for _, p := range m.pagers {
+				cmds = append(cmds, p.view.SetWrap(m.wrap))
+			}
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.scrollLeft):
+			p := m.pagers[m.focusedPager]
+			p.view.ScrollLeft(max(1, p.view.Width/2))
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.scrollRight):
+			p := m.pagers[m.focusedPager]
+			p.view.ScrollRight(max(1, p.view.Width/2))
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.lineNumbers):
+			p := m.pagers[m.focusedPager]
+			p.view.LineNumbers = !p.view.LineNumbers
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.numbersAll):
+			m.lineNumbers = !m.pagers[m.focusedPager].view.LineNumbers
+			for _, p := range m.pagers {
+				p.view.LineNumbers = m.lineNumbers
+			}
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.follow):
+			p := m.pagers[m.focusedPager]
+			cmds = append(cmds, p.view.SetFollow(!p.view.Follow))
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.search):
+			cmds = append(cmds, m.openPrompt(promptSearch, "/ ", m.pagers[m.focusedPager].view.Query()))
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.gotoLine):
+			cmds = append(cmds, m.openPrompt(promptGoto, "go to line: ", ""))
+			m.prompt.Placeholder = "number, percentage or $"
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.diff):
+			if m.focusedPager == 0 {
+				m.SetErr(errors.New("diff: the first column has no input to diff against"))
+			} else {
+				cmds = append(cmds, m.toggleDiff(m.focusedPager))
+			}
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.linkScroll):
+			m.linked = !m.linked
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.top):
+			m.pagers[m.focusedPager].view.GotoTop()
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.bottom):
+			cmds = append(cmds, m.pagers[m.focusedPager].view.GotoBottom())
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.searchNext):
+			m.pagers[m.focusedPager].view.SearchNext()
+			inputMsg = nil
+		case key.Matches(msg, m.keymap.searchPrev):
+			m.pagers[m.focusedPager].view.SearchPrev()
+			inputMsg = nil
+		}
+	case tea.MouseMsg:
+		// The mouse acts only on the column under the pointer.
+		i := m.pagerAt(msg.X, msg.Y)
+		if i < 0 {
+			break
+		}
+		switch msg.Type {
+		case tea.MouseLeft:
+			m.bottomTextInput.SetCursor(m.stagePos(i))
+		case tea.MouseWheelUp, tea.MouseWheelDown:
+			cmds = append(cmds, m.pagers[i].Update(msg))
+			leader = i
 		}
 	case tea.WindowSizeMsg:
 		m.height = msg.Height
 		m.width = msg.Width
 	}
 
-	newBottom, cmd := m.bottomTextInput.Update(msg)
+	newBottom, cmd := m.bottomTextInput.Update(inputMsg)
 	m.bottomTextInput = newBottom
 	cmds = append(cmds, cmd)
 
@@ -222,14 +457,199 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.sizeInputs()
 	}
 
-	for _, p := range m.pagers {
-		cmd := p.Update(msg)
-		cmds = append(cmds, cmd)
+	if _, ok := msg.(tea.MouseMsg); !ok {
+		for _, p := range m.pagers {
+			cmd := p.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+	}
+
+	if m.linked {
+		if leader < 0 {
+			leader = m.focusedPager
+		}
+		cmds = append(cmds, m.syncScroll(leader)...)
 	}
 
 	return m, tea.Batch(cmds...)
 }
 
+// toggleDiff switches pager i between showing its output
+// and showing it as a diff against its input, the output of pager i-1.
+func (m *model) toggleDiff(i int) tea.Cmd {
+	v := m.pagers[i].view
+	if v.Diffing() {
+		return v.SetDiff(nil)
+	}
+	return v.SetDiff(m.pagers[i-1].shared)
+}
+
+// syncScroll scrolls the visible columns to the same line as pager leader,
+// and has them follow their streams if it does,
+// unless their line counts have diverged,
+// in which case it turns linked scrolling off.
+func (m *model) syncScroll(leader int) []tea.Cmd {
+	if m.linesDiverge() {
+		m.linked = false
+		m.SetErr(errors.New("link scroll off: the columns have different numbers of lines"))
+		return nil
+	}
+	lead := m.pagers[leader]
+	if !lead.linkable() {
+		return nil
+	}
+	line := lead.view.TopLine()
+	var cmds []tea.Cmd
+	for _, p := range m.visiblePagers() {
+		if p == lead || !p.linkable() {
+			continue
+		}
+		if p.view.Follow != lead.view.Follow {
+			cmds = append(cmds, p.view.SetFollow(lead.view.Follow))
+		}
+		if p.view.TopLine() != line {
+			// Read ahead, if need be, to catch up with the leader.
+			cmds = append(cmds, p.view.ScrollToLine(line))
+		}
+	}
+	return cmds
+}
+
+// linesDiverge reports whether any visible columns are known to differ in length,
+// because a finished column has a different number of lines from another.
+func (m *model) linesDiverge() bool {
+	done := -1 // the number of lines in finished columns
+	most := 0
+	for _, p := range m.visiblePagers() {
+		if !p.linkable() {
+			continue
+		}
+		// Count lines of the stream, not rows of a diff or hex dump.
+		n := p.shared.Text().NLines()
+		most = max(most, n)
+		if p.shared.Err() != nil {
+			if done >= 0 && n != done {
+				return true
+			}
+			done = n
+		}
+	}
+	return done >= 0 && most > done
+}
+
+// openPrompt gives the keyboard to a prompt in place of the help line.
+func (m *model) openPrompt(mode promptMode, prompt, value string) tea.Cmd {
+	m.promptMode = mode
+	m.prompt.Prompt = prompt
+	m.prompt.Placeholder = ""
+	m.prompt.SetValue(value)
+	m.prompt.CursorEnd()
+	return m.prompt.Focus()
+}
+
+// closePrompt gives the keyboard back to the pipeline.
+func (m *model) closePrompt() {
+	m.promptMode = promptNone
+	m.prompt.Blur()
+}
+
+// updatePrompt handles a key press while a prompt is open.
+func (m *model) updatePrompt(msg tea.KeyMsg) tea.Cmd {
+	if msg.Type != tea.KeyEsc && key.Matches(msg, m.keymap.quit) {
+		return tea.Quit
+	}
+	switch m.promptMode {
+	case promptSearch:
+		return m.updateSearch(msg)
+	case promptGoto:
+		return m.updateGoto(msg)
+	}
+	return nil
+}
+
+// updateSearch handles a key press while the search prompt is open.
+// The focused column is searched as the query is typed.
+func (m *model) updateSearch(msg tea.KeyMsg) tea.Cmd {
+	p := m.pagers[m.focusedPager]
+	switch {
+	case msg.Type == tea.KeyEnter:
+		// Keep the search, and go back to editing the pipeline.
+		m.closePrompt()
+		return nil
+	case msg.Type == tea.KeyEsc:
+		m.closePrompt()
+		return p.view.Search("")
+	case key.Matches(msg, m.keymap.searchNext):
+		p.view.SearchNext()
+		return nil
+	case key.Matches(msg, m.keymap.searchPrev):
+		p.view.SearchPrev()
+		return nil
+	}
+	prev := m.prompt.Value()
+	var cmd tea.Cmd
+	m.prompt, cmd = m.prompt.Update(msg)
+	if q := m.prompt.Value(); q != prev {
+		cmd = tea.Batch(cmd, p.view.Search(q))
+	}
+	return cmd
+}
+
+// updateGoto handles a key press while the go-to prompt is open.
+func (m *model) updateGoto(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.closePrompt()
+		t, err := parseTarget(m.prompt.Value())
+		if err != nil {
+			m.SetErr(err)
+			return nil
+		}
+		v := m.pagers[m.focusedPager].view
+		switch {
+		case t.end:
+			return v.GotoBottom()
+		case t.percent:
+			return v.GotoPercent(t.n)
+		}
+		return v.GotoLine(int(t.n) - 1)
+	case tea.KeyEsc:
+		m.closePrompt()
+		return nil
+	}
+	var cmd tea.Cmd
+	m.prompt, cmd = m.prompt.Update(msg)
+	return cmd
+}
+
+// target is where the go-to prompt was asked to go.
+type target struct {
+	n       float64 // line number or percentage
+	percent bool
+	end     bool
+}
+
+// parseTarget parses an answer to the go-to prompt:
+// a 1-based line number, a percentage such as 50%, or $ for the end.
+func parseTarget(s string) (target, error) {
+	s = strings.TrimSpace(s)
+	if s == "$" {
+		return target{end: true}, nil
+	}
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		n, err := strconv.ParseFloat(pct, 64)
+		if err != nil || n < 0 || n > 100 {
+			return target{}, fmt.Errorf("go to: bad percentage %q", s)
+		}
+		return target{n: n, percent: true}, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return target{}, fmt.Errorf("go to: want a line number, percentage or $, got %q", s)
+	}
+	return target{n: float64(n)}, nil
+}
+
 func (m *model) updatePagers() []tea.Cmd {
 	var cmds []tea.Cmd
 	rawShell := m.bottomTextInput.Value()
@@ -254,6 +674,8 @@ func (m *model) updatePagers() []tea.Cmd {
 	// when I invariably mess up other things
 	for nPagers > len(m.pagers) {
 		p := newEmptyPager()
+		p.view.Wrap = m.wrap
+		p.view.LineNumbers = m.lineNumbers
 		cmds = append(cmds, p.Init())
 		m.pagers = append(m.pagers, p)
 	}
@@ -275,8 +697,19 @@ func (m *model) updatePagers() []tea.Cmd {
 	}
 	if rebuildIdx > 0 {
 		for i := rebuildIdx; i < len(m.pagers); i++ {
-			m.pagers[i].close()
+			old := m.pagers[i]
+			old.close()
 			p := newCommandPager(m.pagers[i-1].shared, m.commands[i-1])
+			// Keep the column looking the same as the stage is edited.
+			if sep := old.view.Sep(); !bytes.Equal(sep, p.view.Sep()) {
+				cmds = append(cmds, p.view.SetSep(sep))
+			}
+			p.view.Settings = old.view.Settings
+			p.view.XOffset = old.view.XOffset
+			cmds = append(cmds, p.view.Search(old.view.Query()))
+			if old.view.Diffing() {
+				cmds = append(cmds, p.view.SetDiff(m.pagers[i-1].shared))
+			}
 			cmds = append(cmds, p.Init())
 			m.pagers[i] = p
 		}
@@ -327,6 +760,7 @@ func (m *model) sizeInputs() {
 
 	m.bottomTextInput.Width = m.width - len(m.bottomTextInput.Prompt)
 	m.errText.Width = m.width
+	m.prompt.Width = m.width - len(m.prompt.Prompt)
 }
 
 func (m *model) SetErr(err error) {
@@ -344,6 +778,30 @@ func (m *model) numVisiblePagers() int {
 	return len(m.visiblePagers())
 }
 
+// pagerAt returns the index of the pager displayed at screen position x, y,
+// or -1 if there is none.
+func (m *model) pagerAt(x, y int) int {
+	if x < 0 || y < 0 || y >= m.height-bottomAreaHeight {
+		return -1
+	}
+	for i := m.minPager; i <= m.maxPager; i++ {
+		w := m.pagers[i].view.Width
+		if x < w {
+			return i
+		}
+		x -= w
+	}
+	return -1
+}
+
+// stagePos returns the pipeline cursor position that focuses pager i.
+func (m *model) stagePos(i int) int {
+	if i < len(m.pipes) {
+		return m.pipes[i]
+	}
+	return len(m.bottomTextInput.Value())
+}
+
 func (m model) View() string {
 	if m.width == 0 {
 		return "loading..."
@@ -364,21 +822,119 @@ func (m model) View() string {
 	if m.err != nil {
 		lastLine = m.errText.View()
 	}
+	if m.promptMode != promptNone {
+		lastLine = m.prompt.View()
+	}
 	all := lipgloss.JoinVertical(lipgloss.Left, inputs, m.bottomTextInput.View(), lastLine)
 	return all
 }
 
 var flagDebugLog = flag.String("log", "", "log to file `log`")
 
+var flagSep = flag.String("sep", "", "split columns into records on `sep` (e.g. \\0 for find -print0) instead of newlines")
+
+var flagEncoding = flag.String("encoding", "", "decode input from `charset` (e.g. latin1, utf-16le, shift_jis) for display; a byte order mark takes precedence")
+
+var flagTranscode = flag.Bool("transcode", false, "pass input to commands transcoded to UTF-8 rather than as is")
+
+var flagTabs = flag.Int("tabs", 8, "put tab stops every `n` columns")
+
+var flagFollow = flag.Bool("follow", false, "keep every column scrolled to its newest output")
+
+var flagTimestamps = flag.Bool("timestamps", false, "record when each line arrives, for the alt+t time gutter")
+
+var flagTail = flag.Int("tail", 0, "keep only the last `n` lines of each column, for endless streams")
+
+var (
+	flagMem       byteSize
+	flagTailBytes byteSize
+)
+
+func init() {
+	flag.Var(&flagMem, "mem", "keep at most `size` bytes (e.g. 512M) of each column in memory, spilling the rest to a temp file")
+	flag.Var(&flagTailBytes, "tail-bytes", "keep only about the last `size` bytes (e.g. 64M) of each column, for endless streams")
+}
+
+// sharedOptions returns the stream options for a new column, per the command line flags.
+func sharedOptions() stream.Options {
+	return stream.Options{
+		MemLimit:   int(flagMem),
+		TailLines:  *flagTail,
+		TailBytes:  int(flagTailBytes),
+		Sep:        sep,
+		Timestamps: *flagTimestamps,
+	}
+}
+
+// inputOptions returns the stream options for the first column, which shows pex's inputs.
+func inputOptions() stream.Options {
+	opts := sharedOptions()
+	if !*flagTranscode {
+		opts.Encoding = inputEncoding
+	}
+	return opts
+}
+
+// inputEncoding is the parsed -encoding flag.
+var inputEncoding encoding.Encoding
+
+// sep is the parsed -sep flag.
+var sep []byte
+
+// parseSep parses a record separator written with Go string escapes, such as \t or \x1e.
+// For convenience, \0 means NUL.
+func parseSep(s string) ([]byte, error) {
+	if s == `\0` {
+		return []byte{0}, nil
+	}
+	u, err := strconv.Unquote(`"` + strings.ReplaceAll(s, `"`, `\"`) + `"`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid separator %q", s)
+	}
+	return []byte(u), nil
+}
+
+// byteSize is a flag.Value for a number of bytes,
+// with an optional K, M, G, or T suffix (powers of 1024).
+type byteSize int64
+
+func (s *byteSize) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *byteSize) Set(v string) error {
+	num := strings.TrimSuffix(strings.ToUpper(v), "B")
+	mult := int64(1)
+	if i := strings.IndexAny(num, "KMGT"); i >= 0 && i == len(num)-1 {
+		mult = 1 << (10 * (1 + strings.IndexByte("KMGT", num[i])))
+		num = num[:i]
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q", v)
+	}
+	*s = byteSize(n * mult)
+	return nil
+}
+
 func main() {
 	// override ErrHelp handling to hide -log flag from regular users, it is for debugging
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, `
 usage:
-  pex [files...]
+  pex [flags] [files...]
 or
-  command | pex
+  command | pex [flags]
+
+flags:
 `[1:])
+		flag.VisitAll(func(f *flag.Flag) {
+			if f.Name == "log" {
+				return
+			}
+			name, usage := flag.UnquoteUsage(f)
+			fmt.Fprintf(os.Stderr, "  -%s %s\n    \t%s\n", f.Name, name, usage)
+		})
 		os.Exit(0)
 	}
 	err := flag.CommandLine.Parse(os.Args[1:])
@@ -404,6 +960,20 @@ or
 	logger := slog.New(lh)
 	slog.SetDefault(logger)
 
+	sep, err = parseSep(*flagSep)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-sep: %v\n", err)
+		os.Exit(2)
+	}
+
+	if *flagEncoding != "" {
+		inputEncoding, err = htmlindex.Get(*flagEncoding)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-encoding: unknown charset %q\n", *flagEncoding)
+			os.Exit(2)
+		}
+	}
+
 	args := flag.Args()
 	m, err := newModel(args)
 	if err != nil {
@@ -413,7 +983,7 @@ or
 
 	p := tea.NewProgram(m,
 		tea.WithAltScreen(),
-		tea.WithMouseCellMotion(), // turn on mouse support so we can track the mouse wheel
+		tea.WithMouseCellMotion(), // turn on mouse support so we can track the mouse wheel and clicks
 	)
 
 	final, err := p.Run()
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/josharian/pex/streamview"
+)
+
+func TestPagerAt(t *testing.T) {
+	m := &model{height: 10, minPager: 1, maxPager: 2}
+	for _, w := range []int{5, 6, 7} {
+		m.pagers = append(m.pagers, &pager{view: &streamview.Model{Width: w}})
+	}
+	for _, tt := range []struct {
+		x, y int
+		want int
+	}{
+		{0, 0, 1},
+		{5, 7, 1},
+		{6, 0, 2},
+		{12, 0, 2},
+		{13, 0, -1}, // past the last column
+		{0, 8, -1},  // the pipeline
+		{-1, 0, -1},
+	} {
+		if got := m.pagerAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("pagerAt(%d, %d) = %d, want %d", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want target
+	}{
+		{"12", target{n: 12}},
+		{" 7 ", target{n: 7}},
+		{"50%", target{n: 50, percent: true}},
+		{"12.5%", target{n: 12.5, percent: true}},
+		{"$", target{end: true}},
+	} {
+		got, err := parseTarget(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseTarget(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "0", "-3", "abc", "101%", "x%"} {
+		if got, err := parseTarget(in); err == nil {
+			t.Errorf("parseTarget(%q) = %+v, want error", in, got)
+		}
+	}
+}
+
+func TestLinesDiverge(t *testing.T) {
+	column := func(s string) *pager {
+		p := newPager(strings.NewReader(s), "test", sharedOptions())
+		p.input = new(inputReader) // linkable, like the input column
+		io.ReadAll(p.shared.Reader())
+		return p
+	}
+	m := &model{pagers: []*pager{column("a\nb\n"), column("1\n2\n"), newEmptyPager()}, maxPager: 2}
+	if m.linesDiverge() {
+		t.Errorf("columns of 2 lines diverge")
+	}
+	// A column showing a diff has more rows than lines.
+	diffed := column("a\nc\n")
+	if cmd := diffed.view.SetDiff(m.pagers[0].shared); cmd != nil {
+		v, _ := diffed.view.Update(cmd())
+		diffed.view = &v
+	}
+	if diffed.view.TotalLineCount() != 3 {
+		t.Fatalf("diff has %d rows, want 3", diffed.view.TotalLineCount())
+	}
+	m.pagers = append(m.pagers, diffed)
+	m.maxPager++
+	if m.linesDiverge() {
+		t.Errorf("columns of 2 lines, one showing a diff, diverge")
+	}
+	m.pagers = append(m.pagers, column("x\n"))
+	m.maxPager++
+	if !m.linesDiverge() {
+		t.Errorf("columns of 2 lines and 1 line don't diverge")
+	}
+}
+
+func TestSyncScroll(t *testing.T) {
+	column := func(s string) *pager {
+		p := newPager(strings.NewReader(s), "test", sharedOptions())
+		p.input = new(inputReader)
+		io.ReadAll(p.shared.Reader())
+		p.view.Height = 2
+		return p
+	}
+	lines := strings.Repeat("x\n", 10)
+	m := &model{pagers: []*pager{column(lines), column(lines), newEmptyPager()}, maxPager: 2}
+	for _, p := range m.pagers {
+		p.view.Follow = true
+	}
+	m.pagers[0].view.SetCurrentLine(8)
+	m.syncScroll(0)
+	if v := m.pagers[1].view; v.TopLine() != 8 || !v.Follow {
+		t.Errorf("following: got line %d, follow %v, want line 8, follow true", v.TopLine(), v.Follow)
+	}
+	if v := m.pagers[2].view; v.CurrentLine != 0 || !v.Follow {
+		t.Errorf("error column: got line %d, follow %v, want it left alone", v.CurrentLine, v.Follow)
+	}
+
+	m.pagers[0].view.LineUp(5)
+	m.syncScroll(0)
+	if v := m.pagers[1].view; v.TopLine() != 3 || v.Follow {
+		t.Errorf("scrolled: got line %d, follow %v, want line 3, follow false", v.TopLine(), v.Follow)
+	}
+}
+
+func TestRebuildKeepsSep(t *testing.T) {
+	if _, err := exec.LookPath("tr"); err != nil {
+		t.Skip("tr not available")
+	}
+	f, err := os.CreateTemp(t.TempDir(), "in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("a\x00b\x00")
+	f.Close()
+	m, err := newModel([]string{f.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.bottomTextInput.SetValue("tr a A ")
+	m.updatePagers()
+	m.pagers[1].toggleNULSep()
+	m.bottomTextInput.SetValue("tr b B ")
+	m.updatePagers()
+	if sep := m.pagers[1].view.Sep(); string(sep) != "\x00" {
+		t.Errorf("after rebuilding the column: got separator %q, want %q", sep, "\x00")
+	}
+	for _, p := range m.pagers {
+		p.close()
+	}
+}
@@ -2,14 +2,73 @@ package stream
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"sort"
 	"sync"
+	"time"
 )
 
+// chunkSize is the size of each of a Buffer's chunks.
+// Chunks are allocated at full capacity and never reallocated,
+// so appending to a Buffer never copies previously appended data.
+const chunkSize = 64 << 10
+
+// ErrDiscarded is returned when reading data that has been discarded
+// to stay within a Buffer's tail limits.
+var ErrDiscarded = errors.New("stream: data discarded")
+
+// A Buffer holds stream data and an index of its lines.
+//
+// Offsets and line numbers are absolute: they count from the start of the stream,
+// including any lines discarded due to tail limits.
 type Buffer struct {
-	mu    sync.Mutex
-	buf   []byte   // TODO: use something rope-like?
-	lines [][3]int // line start / \r / \n
+	mu      sync.Mutex
+	chunks  [][]byte // chunks[i] holds bytes [(base+i)*chunkSize, (base+i+1)*chunkSize)
+	base    int      // number of chunks released before chunks[0]
+	n       int      // total number of bytes
+	lines   [][3]int // line start / \r / separator, starting at line first
+	partial bool     // the last line has no trailing separator yet
+	sep     []byte   // record separator; nil means \n
+
+	// Chunks that exceed the memory limit are moved to an unlinked temp file,
+	// oldest first, into slots of chunkSize bytes. Their entries in chunks are nil.
+	// The slots of discarded chunks are reused, so that with tail limits,
+	// the file stays about as big as the data retained.
+	maxMem  int      // 0 means no limit
+	spill   *os.File // nil until first needed
+	spilled int      // chunks[:spilled] are in the spill file
+	slots   []int    // slots[i] is the slot of chunks[i] in the spill file
+	free    []int    // slots that are no longer in use
+	nslots  int      // number of slots in the spill file
+
+	// Lines beyond the tail limits are discarded, oldest first.
+	maxLines int // 0 means no limit
+	maxBytes int // 0 means no limit
+	first    int // number of discarded lines
+	start    int // offset of the first retained byte
+
+	longest             int // length of the longest line seen
+	firstData, lastData time.Time
+
+	// If timestamps is set, times[i] is when the first byte of lines[i] arrived,
+	// relative to firstData. While the final line is being re-indexed,
+	// times may have one extra element, for it.
+	timestamps bool
+	times      []time.Duration
+	now        time.Time // time of the current Append
+}
+
+func newBuffer(opts Options) *Buffer {
+	return &Buffer{
+		maxMem:     opts.MemLimit,
+		maxLines:   opts.TailLines,
+		maxBytes:   opts.TailBytes,
+		sep:        normSep(opts.Sep),
+		timestamps: opts.Timestamps,
+	}
 }
 
 func (b *Buffer) Append(p []byte) {
@@ -18,80 +77,465 @@ func (b *Buffer) Append(p []byte) {
 	}
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	b.now = time.Now()
+	if b.n == 0 {
+		b.firstData = b.now
+	}
+	b.lastData = b.now
+	off := b.n
+	b.write(p)
+	b.index(off)
+	b.trim()
+	b.spillOld()
+}
+
+// write appends p to b's chunks.
+func (b *Buffer) write(p []byte) {
+	for len(p) > 0 {
+		i := b.n/chunkSize - b.base
+		if i == len(b.chunks) {
+			b.chunks = append(b.chunks, make([]byte, 0, chunkSize))
+		}
+		c := b.chunks[i]
+		n := copy(c[len(c):cap(c)], p)
+		b.chunks[i] = c[:len(c)+n]
+		b.n += n
+		p = p[n:]
+	}
+}
+
+// index adds line offsets for the bytes in [off, b.n).
+// Only the new bytes are scanned, so indexing is O(b.n-off)
+// no matter how long the final line gets.
+func (b *Buffer) index(off int) {
+	if b.sep != nil {
+		b.indexSep(off)
+		return
+	}
+	// Resume the final line, if it is unterminated.
+	start, cr := off, -1
+	if b.partial {
+		last := b.lines[len(b.lines)-1]
+		b.lines = b.lines[:len(b.lines)-1]
+		start = last[0]
+		if last[1] != last[2] {
+			cr = last[1]
+		}
+	}
+	appendLine := func(end int) {
+		lineEnd := end
+		if cr >= 0 {
+			lineEnd = cr
+		}
+		b.addLine([3]int{start, lineEnd, end})
+	}
+	for off < b.n {
+		end := b.chunkEnd(off)
+		seg := b.slice(off, end)
+		for {
+			i := bytes.IndexByte(seg, '\n')
+			if i < 0 {
+				break
+			}
+			if j := bytes.LastIndexByte(seg[:i], '\r'); j >= 0 {
+				cr = off + j
+			}
+			appendLine(off + i)
+			start, cr = off+i+1, -1
+			off += i + 1
+			seg = seg[i+1:]
+		}
+		if j := bytes.LastIndexByte(seg, '\r'); j >= 0 {
+			cr = off + j
+		}
+		off = end
+	}
+	b.partial = start < b.n
+	if b.partial {
+		appendLine(b.n)
+	}
+}
+
+// addLine appends l to the line index.
+func (b *Buffer) addLine(l [3]int) {
+	b.lines = append(b.lines, l)
+	b.longest = max(b.longest, l[1]-l[0])
+	if b.timestamps && len(b.times) < len(b.lines) {
+		b.times = append(b.times, b.now.Sub(b.firstData))
+	}
+}
 
-	// need to re-process the final line
-	if len(b.lines) > 0 {
+// indexSep is index for a custom record separator.
+// There is no special handling of \r.
+func (b *Buffer) indexSep(off int) {
+	start := off
+	if b.partial {
+		start = b.lines[len(b.lines)-1][0]
 		b.lines = b.lines[:len(b.lines)-1]
 	}
+	// Back up in case a separator straddles the previous append.
+	for pos := max(start, off-len(b.sep)+1); ; {
+		i := b.find(pos, b.sep)
+		if i < 0 {
+			break
+		}
+		b.addLine([3]int{start, i, i})
+		start = i + len(b.sep)
+		pos = start
+	}
+	b.partial = start < b.n
+	if b.partial {
+		b.addLine([3]int{start, b.n, b.n})
+	}
+}
+
+// find returns the offset of the first occurrence of sep at or after off, or -1.
+func (b *Buffer) find(off int, sep []byte) int {
+	for off < b.n {
+		end := b.chunkEnd(off)
+		if i := bytes.Index(b.slice(off, end), sep); i >= 0 {
+			return off + i
+		}
+		// Check for a separator straddling the chunk boundary.
+		if end < b.n && len(sep) > 1 {
+			lo := max(off, end-len(sep)+1)
+			if i := bytes.Index(b.slice(lo, min(b.n, end+len(sep)-1)), sep); i >= 0 {
+				return lo + i
+			}
+		}
+		off = end
+	}
+	return -1
+}
+
+// chunkEnd returns the end of the data in the chunk containing off.
+func (b *Buffer) chunkEnd(off int) int {
+	return min(b.n, (off/chunkSize+1)*chunkSize)
+}
 
-	// add line offsets
-	lastLineEnd := b.lastLineEnd()
-	b.buf = append(b.buf, p...)
+// Sep returns the record separator, or nil if lines are separated by \n.
+func (b *Buffer) Sep() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sep
+}
 
-	appendLine := func(start, end int) {
-		line := b.buf[start:end]
-		lineLen := bytes.LastIndexByte(line, '\r')
-		if lineLen < 0 {
-			lineLen = len(line)
+// SetSep sets the record separator and re-indexes all retained data.
+// A nil or "\n" separator splits lines on \n, dropping any final \r.
+// Line numbers restart from FirstLine at the oldest retained byte.
+func (b *Buffer) SetSep(sep []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sep = normSep(sep)
+	oldLines, oldTimes := b.lines, b.times
+	b.lines, b.times = nil, nil
+	b.partial = false
+	b.longest = 0
+	timestamps := b.timestamps
+	b.timestamps = false
+	b.index(b.start)
+	b.timestamps = timestamps
+	if timestamps {
+		// Each new line arrived when the old line containing its start did.
+		b.times = make([]time.Duration, len(b.lines))
+		for i, l := range b.lines {
+			j := sort.Search(len(oldLines), func(j int) bool { return oldLines[j][0] > l[0] }) - 1
+			if j >= 0 {
+				b.times[i] = oldTimes[j]
+			}
 		}
-		b.lines = append(b.lines, [3]int{start, start + lineLen, start + len(line)})
 	}
+}
 
-	lineBuf := b.buf[lastLineEnd:]
-	j := 0
-	for {
-		i := bytes.IndexByte(lineBuf, '\n')
-		if i < 0 {
+func normSep(sep []byte) []byte {
+	if len(sep) == 0 || string(sep) == "\n" {
+		return nil
+	}
+	return bytes.Clone(sep)
+}
+
+// trim discards the oldest lines until b fits within its tail limits.
+// The final line is never discarded.
+func (b *Buffer) trim() {
+	k := 0
+	for k < len(b.lines)-1 {
+		tooMany := b.maxLines > 0 && len(b.lines)-k > b.maxLines
+		tooBig := b.maxBytes > 0 && b.n-b.lines[k][0] > b.maxBytes
+		if !tooMany && !tooBig {
 			break
 		}
-		lineStart := lastLineEnd + j
-		appendLine(lineStart, lineStart+i)
-		j += i + 1
-		lineBuf = lineBuf[i+1:]
+		k++
 	}
-	if b.buf[len(b.buf)-1] != '\n' {
-		appendLine(b.lastLineEnd(), len(b.buf))
+	if k == 0 {
+		return
 	}
+	b.lines = b.lines[k:]
+	if b.timestamps {
+		b.times = b.times[k:]
+	}
+	b.first += k
+	b.start = b.lines[0][0]
+	// Release chunks that hold only discarded data,
+	// and their slots in the spill file.
+	c := b.start/chunkSize - b.base
+	spilled := min(c, b.spilled)
+	b.free = append(b.free, b.slots[:spilled]...)
+	b.slots = b.slots[spilled:]
+	b.spilled -= spilled
+	clear(b.chunks[:c])
+	b.chunks = b.chunks[c:]
+	b.base += c
 }
 
-func (b *Buffer) lastLineEnd() int {
-	lastLineEnd := 0
-	if len(b.lines) > 0 {
-		lastLineEnd = b.lines[len(b.lines)-1][2] + 1
+// spillOld moves the oldest full chunks to the spill file
+// until the chunks in memory fit in b.maxMem.
+// The chunk being appended to always stays in memory.
+func (b *Buffer) spillOld() {
+	for b.maxMem > 0 && (len(b.chunks)-b.spilled)*chunkSize > b.maxMem && b.spilled < len(b.chunks)-1 {
+		if b.spill == nil {
+			f, err := os.CreateTemp("", "pex-")
+			if err != nil {
+				// Keep everything in memory.
+				b.maxMem = 0
+				return
+			}
+			// Unlink immediately, so that the OS cleans up after us
+			// no matter how we exit. (This fails on Windows; oh well.)
+			os.Remove(f.Name())
+			b.spill = f
+		}
+		slot := b.nslots
+		if k := len(b.free); k > 0 {
+			slot = b.free[k-1]
+		}
+		if _, err := b.spill.WriteAt(b.chunks[b.spilled], int64(slot*chunkSize)); err != nil {
+			// Disk full? Keep everything from here on in memory.
+			b.maxMem = 0
+			return
+		}
+		if slot == b.nslots {
+			b.nslots++
+		} else {
+			b.free = b.free[:len(b.free)-1]
+		}
+		b.slots = append(b.slots, slot)
+		b.chunks[b.spilled] = nil
+		b.spilled++
 	}
-	return lastLineEnd
 }
 
+// Close releases the spill file, if any.
+// Spilled data cannot be read after Close.
+func (b *Buffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.spill == nil {
+		return nil
+	}
+	err := b.spill.Close()
+	b.spill = nil
+	b.maxMem = 0
+	return err
+}
+
+// slice returns the bytes in [start, end).
+// It copies only if the range spans multiple chunks.
+func (b *Buffer) slice(start, end int) []byte {
+	if start >= end {
+		return nil
+	}
+	if i := start / chunkSize; i == (end-1)/chunkSize && b.chunks[i-b.base] != nil {
+		return b.chunks[i-b.base][start-i*chunkSize : end-i*chunkSize]
+	}
+	buf := make([]byte, end-start)
+	n, _ := b.copyAt(buf, start)
+	return buf[:n]
+}
+
+// copyAt copies bytes starting at off into p,
+// reading them back from the spill file as needed.
+func (b *Buffer) copyAt(p []byte, off int) (n int, err error) {
+	if off < b.start {
+		return 0, ErrDiscarded
+	}
+	for n < len(p) && off < b.n {
+		i := off/chunkSize - b.base
+		c := b.chunks[i]
+		if c == nil {
+			if b.spill == nil {
+				return n, os.ErrClosed
+			}
+			m := min(len(p)-n, chunkSize-off%chunkSize)
+			k, err := b.spill.ReadAt(p[n:n+m], int64(b.slots[i]*chunkSize+off%chunkSize))
+			n += k
+			if err != nil {
+				return n, err
+			}
+			off += k
+			continue
+		}
+		k := copy(p[n:], c[off%chunkSize:])
+		n += k
+		off += k
+	}
+	return n, nil
+}
+
+// Len returns the total number of bytes appended to b.
 func (b *Buffer) Len() int {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	return len(b.buf)
+	return b.n
 }
 
-func (b *Buffer) ReadAt(p []byte, off int) (n int, err error) {
+// Stats returns statistics about b.
+// Done and Err are always zero; see Shared.Stats.
+func (b *Buffer) Stats() Stats {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	return copy(p, b.buf[off:]), nil
+	return Stats{
+		Bytes:       b.n,
+		Lines:       b.first + len(b.lines),
+		LongestLine: b.longest,
+		First:       b.firstData,
+		Last:        b.lastData,
+	}
 }
 
+// Start returns the offset of the first byte that has not been discarded.
+func (b *Buffer) Start() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.start
+}
+
+// ReadAt implements io.ReaderAt.
+// It returns io.EOF when reading past the end of the data appended so far,
+// and ErrDiscarded when reading data discarded due to tail limits.
+func (b *Buffer) ReadAt(p []byte, off int64) (n int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if off < 0 {
+		return 0, errors.New("stream.Buffer.ReadAt: negative offset")
+	}
+	if off >= int64(b.n) {
+		return 0, io.EOF
+	}
+	n, err = b.copyAt(p, int(off))
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// readFrom is like ReadAt, but skips ahead past any discarded data.
+// It returns the offset following the data read.
+func (b *Buffer) readFrom(p []byte, off int) (n, next int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	off = max(off, b.start)
+	n, err = b.copyAt(p, off)
+	return n, off + n, err
+}
+
+// peek returns buffered data starting at off, through the end of its chunk.
+// It does not copy unless the data has been spilled to disk.
+// Like readFrom, it skips past discarded data, and returns the offset of the returned data.
+// It returns an error if spilled data can't be read back.
+func (b *Buffer) peek(off int) (p []byte, at int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	off = max(off, b.start)
+	end := b.chunkEnd(off)
+	if off >= end {
+		return nil, off, nil
+	}
+	if c := b.chunks[off/chunkSize-b.base]; c != nil {
+		return c[off%chunkSize : end-off/chunkSize*chunkSize], off, nil
+	}
+	p = make([]byte, end-off)
+	n, err := b.copyAt(p, off)
+	return p[:n], off, err
+}
+
+// NLines returns the total number of lines, including discarded lines.
 func (b *Buffer) NLines() int {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	return len(b.lines)
+	return b.first + len(b.lines)
+}
+
+// FirstLine returns the number of the first line that has not been discarded.
+func (b *Buffer) FirstLine() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.first
+}
+
+// LineOffset returns the offset of the start of line n.
+// It returns false if line n does not exist yet or has been discarded.
+func (b *Buffer) LineOffset(n int) (off int, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n -= b.first
+	if n < 0 || n >= len(b.lines) {
+		return 0, false
+	}
+	return b.lines[n][0], true
+}
+
+// LineAt returns the number of the line containing offset off.
+// It returns false if off has been discarded or is past the end of b.
+func (b *Buffer) LineAt(off int) (n int, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if off < b.start || off >= b.n {
+		return 0, false
+	}
+	i := sort.Search(len(b.lines), func(i int) bool { return b.lines[i][0] > off })
+	return b.first + i - 1, true
+}
+
+// LineTime returns when the first byte of line n arrived.
+// It returns false if b is not recording timestamps,
+// or if line n does not exist yet or has been discarded.
+func (b *Buffer) LineTime(n int) (t time.Time, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n -= b.first
+	if !b.timestamps || n < 0 || n >= len(b.lines) {
+		return time.Time{}, false
+	}
+	return b.firstData.Add(b.times[n]), true
 }
 
+// Line returns line n, without its line ending.
+// It returns "" if line n does not exist or has been discarded.
 func (b *Buffer) Line(n int) string {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	n -= b.first
 	if n < 0 || n >= len(b.lines) {
 		return ""
 	}
 	start, end := b.lines[n][0], b.lines[n][1]
-	return string(b.buf[start:end])
+	return string(b.slice(start, end))
+}
+
+// RawLine is like Line, but keeps any \r that preceded the line's \n.
+func (b *Buffer) RawLine(n int) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n -= b.first
+	if n < 0 || n >= len(b.lines) {
+		return ""
+	}
+	start, end := b.lines[n][0], b.lines[n][2]
+	return string(b.slice(start, end))
 }
 
 func (b *Buffer) Debug() string {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	return fmt.Sprintf("%q (%v)", b.buf, b.lines)
+	return fmt.Sprintf("%q (first line %d: %v)", b.slice(b.start, b.n), b.first, b.lines)
 }
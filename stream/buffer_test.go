@@ -1,6 +1,14 @@
 package stream
 
-import "testing"
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestBufferBasic(t *testing.T) {
 	in := []byte("hello\nworld\n")
@@ -42,6 +50,9 @@ func TestBufferLineFeeds(t *testing.T) {
 	if buf.Line(3) != "" {
 		t.Errorf("line 3: got %q, want %q", buf.Line(3), "")
 	}
+	if buf.RawLine(0) != "hello\r" || buf.RawLine(1) != "world" {
+		t.Errorf("raw lines 0, 1: got %q, %q, want %q, %q", buf.RawLine(0), buf.RawLine(1), "hello\r", "world")
+	}
 }
 
 func TestBufferCharAtATime(t *testing.T) {
@@ -110,3 +121,273 @@ func TestBufferHellos(t *testing.T) {
 		t.Errorf("line 17: got %q, want %q", buf.Line(17), "hello")
 	}
 }
+
+func TestBufferChunkBoundaries(t *testing.T) {
+	// Build lines of varying lengths, so that line starts, \r and \n
+	// all land on chunk boundaries at some point.
+	var in []byte
+	var want []string
+	for i := 0; len(in) < 3*chunkSize; i++ {
+		line := strings.Repeat("x", i%1000) + fmt.Sprint(i)
+		want = append(want, line)
+		in = append(in, line...)
+		if i%3 == 0 {
+			in = append(in, '\r')
+		}
+		in = append(in, '\n')
+	}
+	for _, step := range []int{1, 7, 4096, chunkSize - 1, chunkSize, len(in)} {
+		buf := new(Buffer)
+		for p := in; len(p) > 0; {
+			n := min(step, len(p))
+			buf.Append(p[:n])
+			p = p[n:]
+		}
+		if buf.Len() != len(in) {
+			t.Fatalf("step %d: len: got %d, want %d", step, buf.Len(), len(in))
+		}
+		if buf.NLines() != len(want) {
+			t.Fatalf("step %d: nlines: got %d, want %d", step, buf.NLines(), len(want))
+		}
+		for i, w := range want {
+			if got := buf.Line(i); got != w {
+				t.Fatalf("step %d: line %d: got %q, want %q", step, i, got, w)
+			}
+		}
+		got := make([]byte, len(in))
+		n, err := buf.ReadAt(got, 0)
+		if n != len(in) || err != nil || !bytes.Equal(got, in) {
+			t.Fatalf("step %d: ReadAt = %d, %v, contents match %v", step, n, err, bytes.Equal(got, in))
+		}
+	}
+}
+
+func TestBufferLongLineCharAtATime(t *testing.T) {
+	// A single unterminated line must not be rescanned on every append.
+	in := strings.Repeat("abcdefgh", chunkSize/2)
+	buf := new(Buffer)
+	for i := 0; i < len(in); i++ {
+		buf.Append([]byte{in[i]})
+	}
+	if buf.NLines() != 1 {
+		t.Fatalf("nlines: got %d, want %d", buf.NLines(), 1)
+	}
+	if buf.Line(0) != in {
+		t.Errorf("line 0: got %d bytes, want %d", len(buf.Line(0)), len(in))
+	}
+}
+
+func TestBufferSpill(t *testing.T) {
+	buf := newBuffer(Options{MemLimit: 2 * chunkSize})
+	defer buf.Close()
+	var in []byte
+	for i := 0; len(in) < 5*chunkSize; i++ {
+		in = fmt.Appendf(in, "line %d\n", i)
+	}
+	buf.Append(in)
+	if buf.spill == nil || buf.spilled == 0 {
+		t.Fatalf("expected data to spill to disk, spilled %d chunks", buf.spilled)
+	}
+	for i, c := range buf.chunks {
+		if (c == nil) != (i < buf.spilled) {
+			t.Fatalf("chunk %d: in memory %v, spilled %d chunks", i, c != nil, buf.spilled)
+		}
+	}
+	if mem := (len(buf.chunks) - buf.spilled) * chunkSize; mem > 2*chunkSize {
+		t.Errorf("in memory: got %d bytes, want <= %d", mem, 2*chunkSize)
+	}
+	for _, i := range []int{0, 1, 7000, 9000, buf.NLines() - 1} {
+		want := fmt.Sprintf("line %d", i)
+		if got := buf.Line(i); got != want {
+			t.Errorf("line %d: got %q, want %q", i, got, want)
+		}
+	}
+	got := make([]byte, len(in))
+	n, err := buf.ReadAt(got, 0)
+	if n != len(in) || err != nil || !bytes.Equal(got, in) {
+		t.Fatalf("ReadAt = %d, %v, contents match %v", n, err, bytes.Equal(got, in))
+	}
+}
+
+func TestBufferTailBytes(t *testing.T) {
+	buf := newBuffer(Options{TailBytes: 3 * chunkSize})
+	var n int
+	for i := 0; n < 10*chunkSize; i++ {
+		line := fmt.Sprintf("line %d\n", i)
+		buf.Append([]byte(line))
+		n += len(line)
+	}
+	if size := buf.Len() - buf.Start(); size > 3*chunkSize {
+		t.Errorf("retained %d bytes, want <= %d", size, 3*chunkSize)
+	}
+	if buf.base < 6 || len(buf.chunks) > 4 {
+		t.Errorf("released %d chunks, kept %d, want >= 6 and <= 4", buf.base, len(buf.chunks))
+	}
+	first := buf.FirstLine()
+	if got, want := buf.Line(first), fmt.Sprintf("line %d", first); got != want {
+		t.Errorf("first line: got %q, want %q", got, want)
+	}
+	if _, err := buf.ReadAt(make([]byte, 1), 0); err != ErrDiscarded {
+		t.Errorf("ReadAt discarded offset: got err %v, want %v", err, ErrDiscarded)
+	}
+}
+
+func TestBufferSpillTail(t *testing.T) {
+	buf := newBuffer(Options{MemLimit: chunkSize, TailBytes: 3 * chunkSize})
+	defer buf.Close()
+	for i := 0; buf.Len() < 50*chunkSize; i += 100 {
+		var in []byte
+		for j := i; j < i+100; j++ {
+			in = fmt.Appendf(in, "line %d\n", j)
+		}
+		buf.Append(in)
+	}
+	if buf.spill == nil {
+		t.Fatalf("expected data to spill to disk")
+	}
+	fi, err := buf.spill.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := fi.Size(); size > 4*chunkSize {
+		t.Errorf("spill file: got %d bytes, want <= %d", size, 4*chunkSize)
+	}
+	if len(buf.chunks) > 5 {
+		t.Errorf("kept %d chunks, want <= 5", len(buf.chunks))
+	}
+	for _, i := range []int{buf.FirstLine(), buf.FirstLine() + 1000, buf.NLines() - 1} {
+		want := fmt.Sprintf("line %d", i)
+		if got := buf.Line(i); got != want {
+			t.Errorf("line %d: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestBufferSep(t *testing.T) {
+	tests := []struct {
+		sep  string
+		in   string
+		want []string
+	}{
+		{"\x00", "a.go\x00dir/b.go\x00c\r\n.go\x00", []string{"a.go", "dir/b.go", "c\r\n.go"}},
+		{"\x00", "x\x00\x00y", []string{"x", "", "y"}},
+		{"--", "one--two---three--", []string{"one", "two", "-three"}},
+		{"\r\n\r\n", "a\r\nb\r\n\r\nc\r\n", []string{"a\r\nb", "c\r\n"}},
+	}
+	for _, tt := range tests {
+		for _, step := range []int{1, 2, 3, len(tt.in)} {
+			buf := newBuffer(Options{Sep: []byte(tt.sep)})
+			for p := tt.in; len(p) > 0; {
+				n := min(step, len(p))
+				buf.Append([]byte(p[:n]))
+				p = p[n:]
+			}
+			var got []string
+			for i := 0; i < buf.NLines(); i++ {
+				got = append(got, buf.Line(i))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("sep %q, in %q, step %d: got %q, want %q", tt.sep, tt.in, step, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestBufferSepChunkBoundary(t *testing.T) {
+	// Put a multi-byte separator across the boundary between two chunks.
+	in := strings.Repeat("x", chunkSize-2) + "<SEP>y<SEP>z"
+	buf := newBuffer(Options{Sep: []byte("<SEP>")})
+	buf.Append([]byte(in))
+	if buf.NLines() != 3 {
+		t.Fatalf("nlines: got %d, want %d", buf.NLines(), 3)
+	}
+	if got := buf.Line(1); got != "y" {
+		t.Errorf("line 1: got %q, want %q", got, "y")
+	}
+}
+
+func TestBufferSetSep(t *testing.T) {
+	buf := new(Buffer)
+	buf.Append([]byte("a\x00b\nc\x00d\r\n"))
+	if buf.NLines() != 2 {
+		t.Fatalf("nlines: got %d, want %d", buf.NLines(), 2)
+	}
+	buf.SetSep([]byte{0})
+	if buf.NLines() != 3 || buf.Line(1) != "b\nc" || buf.Line(2) != "d\r\n" {
+		t.Errorf("NUL-separated: got %s", buf.Debug())
+	}
+	buf.SetSep(nil)
+	if buf.NLines() != 2 || buf.Line(1) != "c\x00d" {
+		t.Errorf("newline-separated: got %s", buf.Debug())
+	}
+}
+
+func TestBufferReadAt(t *testing.T) {
+	buf := new(Buffer)
+	buf.Append([]byte("hello\nworld\n"))
+	p := make([]byte, 5)
+	if n, err := buf.ReadAt(p, 6); n != 5 || err != nil || string(p) != "world" {
+		t.Errorf("ReadAt(6) = %d, %v, %q", n, err, p[:n])
+	}
+	if n, err := buf.ReadAt(p, 9); n != 3 || err != io.EOF || string(p[:n]) != "ld\n" {
+		t.Errorf("ReadAt(9) = %d, %v, %q, want 3, EOF", n, err, p[:n])
+	}
+	if n, err := buf.ReadAt(p, 100); n != 0 || err != io.EOF {
+		t.Errorf("ReadAt(100) = %d, %v, want 0, EOF", n, err)
+	}
+	if _, err := buf.ReadAt(p, -1); err == nil {
+		t.Errorf("ReadAt(-1): got nil error")
+	}
+}
+
+func TestBufferLineAt(t *testing.T) {
+	buf := newBuffer(Options{TailLines: 2})
+	buf.Append([]byte("a\nbb\nccc\nd"))
+	for _, tt := range []struct {
+		off  int
+		line int
+		ok   bool
+	}{
+		{0, 0, false}, // discarded
+		{2, 0, false}, // discarded
+		{5, 2, true},
+		{8, 2, true}, // newline
+		{9, 3, true},
+		{10, 0, false},
+	} {
+		if line, ok := buf.LineAt(tt.off); line != tt.line || ok != tt.ok {
+			t.Errorf("LineAt(%d) = %d, %v, want %d, %v", tt.off, line, ok, tt.line, tt.ok)
+		}
+	}
+}
+
+func TestBufferLineTime(t *testing.T) {
+	buf := newBuffer(Options{Timestamps: true})
+	buf.Append([]byte("a\nb"))
+	t0, _ := buf.LineTime(0)
+	time.Sleep(10 * time.Millisecond)
+	buf.Append([]byte("b\nc\x00d\n"))
+	t1, ok := buf.LineTime(1)
+	if !ok || !t1.Equal(t0) {
+		t.Errorf("line 1 started with line 0, got time %v (ok %v), want %v", t1, ok, t0)
+	}
+	t2, ok := buf.LineTime(2)
+	if !ok || t2.Sub(t0) < 10*time.Millisecond {
+		t.Errorf("line 2: got %v after line 0 (ok %v), want >= 10ms", t2.Sub(t0), ok)
+	}
+	if _, ok := buf.LineTime(3); ok {
+		t.Errorf("line 3: got ok, want not ok")
+	}
+	// Re-indexing keeps times.
+	buf.SetSep([]byte{0})
+	if got, _ := buf.LineTime(0); !got.Equal(t0) {
+		t.Errorf("after SetSep, line 0: got %v, want %v", got, t0)
+	}
+	if got, _ := buf.LineTime(1); !got.Equal(t2) {
+		t.Errorf("after SetSep, line 1: got %v, want %v", got, t2)
+	}
+
+	if _, ok := new(Buffer).LineTime(0); ok {
+		t.Errorf("without timestamps: got ok, want not ok")
+	}
+}
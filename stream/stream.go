@@ -1,59 +1,389 @@
 package stream
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io"
 	"sync"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+var (
+	_ io.ReaderAt = (*Buffer)(nil)
+	_ io.WriterTo = (*Reader)(nil)
+	_ io.Seeker   = (*Reader)(nil)
 )
 
-const pageSize = 4096
+// fetchSize is the size of each read from a Shared's underlying reader.
+const fetchSize = 32 << 10
 
 // Shared is a shared Reader.
 // It can be consumed concurrently by multiple Readers.
 // It caches data in a buffer so that new Readers can start any time.
 type Shared struct {
-	buf *Buffer
-	mu  sync.Mutex // protects reads from r
-	r   io.Reader
+	buf  *Buffer
+	r    io.Reader
+	opts Options
+
+	// text is s's data decoded to UTF-8, for display, if that differs from buf.
+	// It is set at most once, by fetch before any data is appended to buf.
+	text    *Buffer
+	decoder io.WriteCloser // writes decoded data to text
+	sniffed bool           // fetch has checked for a byte order mark
+
+	mu sync.Mutex
+	// fetching is non-nil while a read from r is in flight,
+	// and is closed when that read completes.
+	// Only one read at a time is in flight;
+	// Readers that need more data in the meantime wait for it.
+	// The read happens in its own goroutine, so that waiting Readers can give up.
+	fetching chan struct{}
+	scratch  []byte        // for reads from r
+	changed  chan struct{} // closed when buf grows or the stream ends; nil if no one is waiting
+	done     chan struct{} // closed when the stream ends
+	err      error         // the error that ended the stream
 }
 
-func NewShared(r io.Reader) *Shared {
-	return &Shared{r: r, buf: new(Buffer)}
+// Options configures a Shared.
+type Options struct {
+	// MemLimit is the maximum number of bytes of stream data to keep in memory.
+	// Older data is moved to a temporary file and read back as needed.
+	// Zero means no limit.
+	MemLimit int
+
+	// TailLines and TailBytes limit how much of the stream is retained.
+	// Once a limit is exceeded, the oldest lines are discarded.
+	// Readers that fall behind skip ahead to the oldest retained data.
+	// Zero means no limit.
+	TailLines int
+	TailBytes int
+
+	// Sep is the record separator used to split the stream into lines,
+	// such as "\x00" for the output of find -print0.
+	// The default, nil, splits on \n and drops any \r preceding it.
+	// Sep affects only how the data is indexed; Readers see it unchanged.
+	Sep []byte
+
+	// Timestamps enables recording when each line arrives; see Buffer.LineTime.
+	Timestamps bool
+
+	// Encoding is the character encoding of the stream.
+	// If it is set, or if the stream starts with a UTF-8 or UTF-16 byte order mark,
+	// the stream is also decoded to UTF-8 for display; see Shared.Text.
+	// A byte order mark takes precedence over Encoding.
+	// Readers always see the original bytes.
+	Encoding encoding.Encoding
+}
+
+func NewShared(r io.Reader, opts Options) *Shared {
+	s := &Shared{r: r, opts: opts, buf: newBuffer(opts), done: make(chan struct{})}
+	if opts.Encoding != nil {
+		s.decodeTo(newBuffer(opts), opts.Encoding)
+	}
+	return s
 }
 
 func (s *Shared) Reader() *Reader {
 	return &Reader{s: s}
 }
 
+// Buffer returns the buffer holding s's data, as Readers see it.
 func (s *Shared) Buffer() *Buffer {
 	return s.buf
 }
 
+// Text returns the buffer holding s's data as UTF-8 text, for display.
+// It is the same as Buffer unless s's data needs decoding; see Options.Encoding.
+// Once data has arrived, Text always returns the same buffer.
+func (s *Shared) Text() *Buffer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.text != nil {
+		return s.text
+	}
+	return s.buf
+}
+
+// decodeTo arranges for s's data to be decoded from enc into text.
+func (s *Shared) decodeTo(text *Buffer, enc encoding.Encoding) {
+	s.mu.Lock()
+	s.text = text
+	s.mu.Unlock()
+	s.sniffed = true
+	s.decoder = transform.NewWriter(appender{text}, unicode.BOMOverride(enc.NewDecoder()))
+}
+
+// sniff checks whether p, the start of s's data, begins with a byte order mark.
+// If so, s's data will be decoded accordingly.
+func (s *Shared) sniff(p []byte) {
+	s.sniffed = true
+	for _, bom := range []string{"\xef\xbb\xbf", "\xff\xfe", "\xfe\xff"} {
+		if bytes.HasPrefix(p, []byte(bom)) {
+			// BOMOverride does the rest.
+			s.decodeTo(newBuffer(s.opts), encoding.Nop)
+			return
+		}
+	}
+}
+
+// appender is an io.Writer that appends to a Buffer.
+type appender struct{ b *Buffer }
+
+func (w appender) Write(p []byte) (int, error) {
+	w.b.Append(p)
+	return len(p), nil
+}
+
+// Done returns a channel that is closed when the stream ends,
+// because the underlying reader returned an error (including io.EOF).
+// After Done is closed, Err returns that error.
+func (s *Shared) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error that ended the stream, or nil if it is still going.
+// A stream that ended normally has Err io.EOF.
+func (s *Shared) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Stats returns statistics about s.
+// Lines are counted in s's text; see Text.
+func (s *Shared) Stats() Stats {
+	st := s.buf.Stats()
+	if text := s.Text(); text != s.buf {
+		tst := text.Stats()
+		st.Lines, st.LongestLine = tst.Lines, tst.LongestLine
+	}
+	st.Err = s.Err()
+	st.Done = st.Err != nil
+	return st
+}
+
+// Stats are statistics about a stream.
+type Stats struct {
+	Bytes       int       // total bytes, including discarded bytes
+	Lines       int       // total lines, including discarded lines and any unterminated final line
+	LongestLine int       // length in bytes of the longest line seen, excluding its line ending
+	First, Last time.Time // when data first and most recently arrived; zero if none has
+	Done        bool      // whether the stream has ended
+	Err         error     // the error that ended the stream; io.EOF if it ended normally
+}
+
+// BytesPerSec returns the average rate at which data arrived,
+// from the first data to the most recent.
+// It returns 0 if there is not yet enough data to tell.
+func (st Stats) BytesPerSec() float64 {
+	d := st.Last.Sub(st.First).Seconds()
+	if d <= 0 {
+		return 0
+	}
+	return float64(st.Bytes) / d
+}
+
+// Changed returns a channel that is closed the next time s's buffer grows,
+// or the stream ends.
+// Once closed, call Changed again to wait for the next change.
+func (s *Shared) Changed() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.changed == nil {
+		s.changed = make(chan struct{})
+	}
+	return s.changed
+}
+
+// notify wakes everyone waiting on Changed.
+// s.mu must be held.
+func (s *Shared) notify() {
+	if s.changed != nil {
+		close(s.changed)
+		s.changed = nil
+	}
+}
+
+// Close releases resources held by s's buffers.
+// It does not close the underlying reader.
+func (s *Shared) Close() error {
+	err := s.buf.Close()
+	if text := s.Text(); text != s.buf {
+		text.Close()
+	}
+	return err
+}
+
 type Reader struct {
 	s   *Shared
+	ctx context.Context
 	off int
 }
 
+// ReaderContext is like Reader, but the returned Reader's Read
+// gives up and returns ctx.Err() once ctx is done.
+func (s *Shared) ReaderContext(ctx context.Context) *Reader {
+	return &Reader{s: s, ctx: ctx}
+}
+
+// Read reads the next data from the stream.
+// Once all data is consumed, it returns the error that ended the stream,
+// which is the same for all Readers: io.EOF or whatever else the underlying reader failed with.
 func (r *Reader) Read(p []byte) (int, error) {
-	// Use cached data if it exists.
-	if r.off < r.s.buf.Len() {
-		n, err := r.s.buf.ReadAt(p, r.off)
-		r.off += n
-		return n, err
+	return r.ReadContext(r.context(), p)
+}
+
+// ReadContext is like Read, but returns ctx.Err() if ctx is done
+// before any data is available.
+func (r *Reader) ReadContext(ctx context.Context, p []byte) (int, error) {
+	for {
+		// Use cached data if it exists.
+		if r.off < r.s.buf.Len() {
+			return r.readCached(p)
+		}
+		if err := r.wait(ctx); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// WriteTo implements io.WriterTo.
+// It writes buffered data to w directly, without copying it first.
+// It returns once the stream ends (returning nil for io.EOF),
+// or, for Readers from ReaderContext, once the context is done.
+func (r *Reader) WriteTo(w io.Writer) (n int64, err error) {
+	for {
+		p, off, err := r.s.buf.peek(r.off)
+		r.off = off
+		if err != nil {
+			return n, err
+		}
+		if len(p) == 0 {
+			if err := r.wait(r.context()); err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				return n, err
+			}
+			continue
+		}
+		m, err := w.Write(p)
+		n += int64(m)
+		r.off += m
+		if err == nil && m < len(p) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// Seek implements io.Seeker.
+// io.SeekEnd is relative to the end of the data buffered so far.
+// It is fine to seek past the end of the buffered data;
+// reads will wait until the stream reaches that point.
+// Use Buffer.LineOffset to seek to the start of a line.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += int64(r.off)
+	case io.SeekEnd:
+		offset += int64(r.s.buf.Len())
+	default:
+		return 0, errors.New("stream.Reader.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("stream.Reader.Seek: negative position")
+	}
+	r.off = int(offset)
+	return offset, nil
+}
+
+// wait waits until there might be data past r.off,
+// starting a read from the underlying reader if necessary.
+// It returns the error that ended the stream, or ctx.Err() if ctx is done first.
+func (r *Reader) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	// Fetch more data. Lock to single-flight.
 	r.s.mu.Lock()
-	defer r.s.mu.Unlock()
 	// Check again in case we lost a race,
 	// and some data is now available.
 	if r.off < r.s.buf.Len() {
-		n, err := r.s.buf.ReadAt(p, r.off)
-		r.off += n
-		return n, err
+		r.s.mu.Unlock()
+		return nil
+	}
+	if err := r.s.err; err != nil {
+		// The stream has ended; don't touch r.s.r again.
+		r.s.mu.Unlock()
+		return err
+	}
+	fetching := r.s.fetching
+	if fetching == nil {
+		fetching = make(chan struct{})
+		r.s.fetching = fetching
+		go r.s.fetch(fetching)
+	}
+	r.s.mu.Unlock()
+	select {
+	case <-fetching:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Reader) context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
 	}
-	// Read from underlying reader.
-	n, err := r.s.r.Read(p)
+	return r.ctx
+}
+
+// fetch does a single read from the underlying reader into the buffer,
+// and closes fetching when done.
+func (s *Shared) fetch(fetching chan struct{}) {
+	if s.scratch == nil {
+		s.scratch = make([]byte, fetchSize)
+	}
+	n, err := s.r.Read(s.scratch)
 	if n > 0 {
-		r.s.buf.Append(p[:n])
+		if !s.sniffed {
+			s.sniff(s.scratch[:n])
+		}
+		s.buf.Append(s.scratch[:n])
+		if s.decoder != nil {
+			s.decoder.Write(s.scratch[:n])
+		}
 	}
+	if err != nil && s.decoder != nil {
+		// Flush any incomplete trailing sequence.
+		s.decoder.Close()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.err = err
+		close(s.done)
+	}
+	close(fetching)
+	s.fetching = nil
+	if n > 0 || err != nil {
+		s.notify()
+	}
+}
+
+// readCached reads buffered data starting at r.off.
+// If that data has been discarded, it skips ahead to the oldest retained data.
+func (r *Reader) readCached(p []byte) (n int, err error) {
+	n, r.off, err = r.s.buf.readFrom(p, r.off)
 	return n, err
 }
@@ -0,0 +1,369 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestReaderReadAll(t *testing.T) {
+	in := strings.Repeat("hello world\n", 10000)
+	s := NewShared(strings.NewReader(in), Options{})
+	r1, r2 := s.Reader(), s.Reader()
+	for i, r := range []*Reader{r1, r2} {
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("reader %d: %v", i, err)
+		}
+		if string(got) != in {
+			t.Errorf("reader %d: got %d bytes, want %d", i, len(got), len(in))
+		}
+	}
+}
+
+func TestReaderTail(t *testing.T) {
+	var in strings.Builder
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&in, "line %d\n", i)
+	}
+	s := NewShared(strings.NewReader(in.String()), Options{TailLines: 100})
+	fast := s.Reader()
+	if _, err := io.Copy(io.Discard, fast); err != nil {
+		t.Fatal(err)
+	}
+	buf := s.Buffer()
+	if buf.NLines() != 100000 {
+		t.Errorf("nlines: got %d, want %d", buf.NLines(), 100000)
+	}
+	if buf.FirstLine() != 100000-100 {
+		t.Errorf("first line: got %d, want %d", buf.FirstLine(), 100000-100)
+	}
+	if got, want := buf.Line(99999), "line 99999"; got != want {
+		t.Errorf("last line: got %q, want %q", got, want)
+	}
+	if got := buf.Line(5); got != "" {
+		t.Errorf("discarded line: got %q, want %q", got, "")
+	}
+	// A new reader starts at the oldest retained line.
+	got, err := io.ReadAll(s.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(got), "line 99900\n") || strings.Count(string(got), "\n") != 100 {
+		t.Errorf("late reader: got %d lines starting %.20q", strings.Count(string(got), "\n"), got)
+	}
+}
+
+func TestReaderConcurrent(t *testing.T) {
+	// Many readers racing on a slow stream must all see all of it,
+	// and must all see the error that ended it.
+	pr, pw := io.Pipe()
+	s := NewShared(pr, Options{})
+	const nReaders = 10
+	type result struct {
+		data string
+		err  error
+	}
+	results := make(chan result)
+	for i := 0; i < nReaders; i++ {
+		r := s.Reader()
+		go func() {
+			var data []byte
+			buf := make([]byte, 3)
+			for {
+				n, err := r.Read(buf)
+				data = append(data, buf[:n]...)
+				if err != nil {
+					results <- result{string(data), err}
+					return
+				}
+			}
+		}()
+	}
+	var want strings.Builder
+	for i := 0; i < 100; i++ {
+		line := fmt.Sprintf("line %d\n", i)
+		want.WriteString(line)
+		pw.Write([]byte(line))
+	}
+	errBoom := errors.New("boom")
+	pw.CloseWithError(errBoom)
+	for i := 0; i < nReaders; i++ {
+		res := <-results
+		if res.data != want.String() {
+			t.Errorf("got %d bytes, want %d", len(res.data), want.Len())
+		}
+		if res.err != errBoom {
+			t.Errorf("got err %v, want %v", res.err, errBoom)
+		}
+	}
+}
+
+func TestSharedChanged(t *testing.T) {
+	pr, pw := io.Pipe()
+	s := NewShared(pr, Options{})
+	changed := s.Changed()
+	go s.Reader().Read(make([]byte, 10))
+	select {
+	case <-changed:
+		t.Fatal("changed before any data arrived")
+	case <-time.After(10 * time.Millisecond):
+	}
+	pw.Write([]byte("hi\n"))
+	<-changed
+	if s.Buffer().NLines() != 1 {
+		t.Errorf("nlines: got %d, want %d", s.Buffer().NLines(), 1)
+	}
+}
+
+// failReader returns data, then err, then fails the test if it is read again.
+type failReader struct {
+	t    *testing.T
+	data string
+	err  error
+	done bool
+}
+
+func (r *failReader) Read(p []byte) (int, error) {
+	if r.done {
+		r.t.Errorf("Read after terminal error")
+		return 0, errors.New("read after terminal error")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	if len(r.data) == 0 {
+		r.done = true
+		return n, r.err
+	}
+	return n, nil
+}
+
+func TestSharedErr(t *testing.T) {
+	for _, want := range []error{io.EOF, io.ErrClosedPipe} {
+		s := NewShared(&failReader{t: t, data: "hello\nworld\n", err: want}, Options{})
+		if err := s.Err(); err != nil {
+			t.Errorf("Err before reading: got %v, want nil", err)
+		}
+		select {
+		case <-s.Done():
+			t.Errorf("Done before reading")
+		default:
+		}
+		for i := 0; i < 3; i++ {
+			got, err := io.ReadAll(s.Reader())
+			if string(got) != "hello\nworld\n" {
+				t.Errorf("reader %d: got %q", i, got)
+			}
+			if want != io.EOF && err != want {
+				t.Errorf("reader %d: got err %v, want %v", i, err, want)
+			}
+			// A reader that is already done keeps returning the same error.
+			r := s.Reader()
+			io.ReadAll(r)
+			if _, err := r.Read(make([]byte, 1)); err != want {
+				t.Errorf("reader %d: second read got err %v, want %v", i, err, want)
+			}
+		}
+		<-s.Done()
+		if err := s.Err(); err != want {
+			t.Errorf("Err: got %v, want %v", err, want)
+		}
+	}
+}
+
+func TestReadContextLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	pr, pw := io.Pipe()
+	s := NewShared(pr, Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	const nReaders = 10
+	errc := make(chan error)
+	for i := 0; i < nReaders; i++ {
+		r := s.ReaderContext(ctx)
+		go func() {
+			_, err := io.ReadAll(r)
+			errc <- err
+		}()
+	}
+	// Let the readers block waiting for data that never comes.
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	for i := 0; i < nReaders; i++ {
+		select {
+		case err := <-errc:
+			if err != context.Canceled {
+				t.Errorf("got err %v, want %v", err, context.Canceled)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("reader did not return after cancellation")
+		}
+	}
+
+	// The read from the pipe is still in flight.
+	// It finishes once the pipe is closed, such as when a process is killed.
+	pw.Close()
+	<-s.Done()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			t.Fatalf("leaked %d goroutines:\n%s", runtime.NumGoroutine()-before, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// writeCounter counts the writes made to it.
+type writeCounter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestReaderWriteTo(t *testing.T) {
+	in := strings.Repeat("0123456789abcdef\n", 3*chunkSize/17)
+	s := NewShared(strings.NewReader(in), Options{})
+	// Fill the buffer, so that WriteTo can write whole chunks.
+	io.Copy(io.Discard, s.Reader())
+	w := new(writeCounter)
+	n, err := s.Reader().WriteTo(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(in)) || w.String() != in {
+		t.Fatalf("WriteTo wrote %d bytes, want %d", n, len(in))
+	}
+	if w.writes > 3 {
+		t.Errorf("WriteTo made %d writes, want <= 3", w.writes)
+	}
+}
+
+func TestReaderWriteToClosed(t *testing.T) {
+	in := strings.Repeat("0123456789abcdef\n", 3*chunkSize/17)
+	s := NewShared(strings.NewReader(in), Options{MemLimit: chunkSize})
+	io.Copy(io.Discard, s.Reader())
+	s.Close()
+	// The spilled data can't be read back, which WriteTo must report, not retry.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := s.ReaderContext(ctx).WriteTo(io.Discard)
+	if err == nil || err == ctx.Err() {
+		t.Errorf("WriteTo after Close: got err %v, want a read error", err)
+	}
+}
+
+func TestReaderSeek(t *testing.T) {
+	s := NewShared(strings.NewReader("zero\none\ntwo\nthree\n"), Options{})
+	io.Copy(io.Discard, s.Reader())
+	off, ok := s.Buffer().LineOffset(2)
+	if !ok {
+		t.Fatal("LineOffset(2) not ok")
+	}
+	r := s.Reader()
+	if _, err := r.Seek(int64(off), io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(r)
+	if string(got) != "two\nthree\n" {
+		t.Errorf("after seek to line 2: got %q", got)
+	}
+	if pos, err := r.Seek(-6, io.SeekCurrent); err != nil || pos != 13 {
+		t.Errorf("Seek(-6, io.SeekCurrent) = %d, %v, want 13, nil", pos, err)
+	}
+	got, _ = io.ReadAll(r)
+	if string(got) != "three\n" {
+		t.Errorf("after seek back: got %q", got)
+	}
+	if _, err := r.Seek(-100, io.SeekEnd); err == nil {
+		t.Error("Seek before start: got nil error")
+	}
+	if _, ok := s.Buffer().LineOffset(4); ok {
+		t.Error("LineOffset(4) ok, want not ok")
+	}
+}
+
+func TestSharedStats(t *testing.T) {
+	pr, pw := io.Pipe()
+	s := NewShared(pr, Options{})
+	r := s.Reader()
+	go func() {
+		pw.Write([]byte("a\nlongest line\n"))
+		time.Sleep(10 * time.Millisecond)
+		pw.Write([]byte("b\nunterminated"))
+		pw.Close()
+	}()
+	if st := s.Stats(); st.Bytes != 0 || !st.First.IsZero() || st.Done {
+		t.Errorf("before reading: got %+v", st)
+	}
+	io.ReadAll(r)
+	st := s.Stats()
+	if st.Bytes != 29 || st.Lines != 4 || st.LongestLine != 12 {
+		t.Errorf("bytes, lines, longest: got %d, %d, %d, want 29, 4, 12", st.Bytes, st.Lines, st.LongestLine)
+	}
+	if !st.Done || st.Err != io.EOF {
+		t.Errorf("done, err: got %v, %v, want true, EOF", st.Done, st.Err)
+	}
+	if d := st.Last.Sub(st.First); d < 10*time.Millisecond {
+		t.Errorf("last - first: got %v, want >= 10ms", d)
+	}
+	if st.BytesPerSec() <= 0 {
+		t.Errorf("bytes/sec: got %v, want > 0", st.BytesPerSec())
+	}
+}
+
+func TestSharedText(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		enc    encoding.Encoding
+		lines  []string
+		decode bool
+	}{
+		{"plain", "a\nb\n", nil, []string{"a", "b"}, false},
+		{"utf-8 bom", "\xef\xbb\xbfa\nb\n", nil, []string{"a", "b"}, true},
+		{"utf-16le bom", "\xff\xfea\x00\n\x00\xe9\x00\n\x00", nil, []string{"a", "é"}, true},
+		{"utf-16be bom", "\xfe\xff\x00a\x00\n\x00\xe9\x00\n", nil, []string{"a", "é"}, true},
+		{"latin-1", "caf\xe9\n", charmap.ISO8859_1, []string{"café"}, true},
+		{"bom beats encoding", "\xff\xfea\x00\n\x00", charmap.ISO8859_1, []string{"a"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewShared(strings.NewReader(tt.in), Options{Encoding: tt.enc})
+			got, err := io.ReadAll(s.Reader())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.in {
+				t.Errorf("reader got %q, want original %q", got, tt.in)
+			}
+			text := s.Text()
+			if decoded := text != s.Buffer(); decoded != tt.decode {
+				t.Errorf("decoded: got %v, want %v", decoded, tt.decode)
+			}
+			var lines []string
+			for i := 0; i < text.NLines(); i++ {
+				lines = append(lines, text.Line(i))
+			}
+			if fmt.Sprint(lines) != fmt.Sprint(tt.lines) {
+				t.Errorf("lines: got %q, want %q", lines, tt.lines)
+			}
+			if st := s.Stats(); st.Bytes != len(tt.in) || st.Lines != len(tt.lines) {
+				t.Errorf("stats: got %d bytes, %d lines, want %d, %d", st.Bytes, st.Lines, len(tt.in), len(tt.lines))
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package streamview
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ANSIMode selects how ANSI escape sequences in a stream are shown.
+type ANSIMode int
+
+const (
+	ANSIRender ANSIMode = iota // show SGR colors and attributes; drop other escapes
+	ANSIStrip                  // drop all escapes
+)
+
+// span is a run of text with a single style.
+type span struct {
+	text  string
+	style sgr
+}
+
+// sgr is the state set by SGR (Select Graphic Rendition) escape sequences.
+// Colors are in lipgloss.Color syntax; "" means the terminal default.
+type sgr struct {
+	fg, bg                                          string
+	bold, faint, italic, underline, reverse, strike bool
+}
+
+// lipgloss returns the lipgloss equivalent of s.
+func (s sgr) lipgloss() lipgloss.Style {
+	st := lipgloss.NewStyle().
+		Bold(s.bold).
+		Faint(s.faint).
+		Italic(s.italic).
+		Underline(s.underline).
+		Reverse(s.reverse).
+		Strikethrough(s.strike)
+	if s.fg != "" {
+		st = st.Foreground(lipgloss.Color(s.fg))
+	}
+	if s.bg != "" {
+		st = st.Background(lipgloss.Color(s.bg))
+	}
+	return st
+}
+
+// parseANSI splits line into styled spans, interpreting SGR sequences
+// and dropping all other escape sequences, such as cursor movement,
+// screen clearing and OSC sequences.
+func parseANSI(line string) []span {
+	if !strings.Contains(line, "\x1b") {
+		return []span{{text: line}}
+	}
+	var spans []span
+	var cur sgr
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			spans = append(spans, span{text: line[start:end], style: cur})
+		}
+	}
+	for i := 0; i < len(line); {
+		if line[i] != '\x1b' {
+			i++
+			continue
+		}
+		flush(i)
+		n, params, final := escapeLen(line[i:])
+		if final == 'm' {
+			cur = cur.apply(params)
+		}
+		i += n
+		start = i
+	}
+	flush(len(line))
+	return spans
+}
+
+// escapeLen returns the length of the escape sequence at the start of s,
+// which begins with ESC.
+// For CSI sequences, it also returns the parameters and final byte.
+// An unterminated sequence extends to the end of s.
+func escapeLen(s string) (n int, params string, final byte) {
+	if len(s) < 2 {
+		return len(s), "", 0
+	}
+	switch s[1] {
+	case '[': // CSI: parameter bytes, intermediate bytes, final byte
+		for i := 2; i < len(s); i++ {
+			if c := s[i]; c >= 0x40 && c <= 0x7e {
+				return i + 1, s[2:i], c
+			}
+		}
+		return len(s), "", 0
+	case ']', 'P', 'X', '^', '_': // OSC, DCS, SOS, PM, APC: terminated by BEL or ST
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1, "", 0
+			}
+			if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2, "", 0
+			}
+		}
+		return len(s), "", 0
+	}
+	// Other escapes: intermediate bytes, then a final byte.
+	i := 1
+	for i < len(s) && s[i] >= 0x20 && s[i] <= 0x2f {
+		i++
+	}
+	return min(i+1, len(s)), "", 0
+}
+
+// apply returns s updated by the SGR parameters params, such as "1;38;5;208".
+func (s sgr) apply(params string) sgr {
+	// Treat colon-separated subparameters (38:5:208) like parameters.
+	fields := strings.FieldsFunc(params, func(r rune) bool { return r == ';' || r == ':' })
+	if len(fields) == 0 {
+		return sgr{}
+	}
+	for i := 0; i < len(fields); i++ {
+		p, err := strconv.Atoi(fields[i])
+		if err != nil {
+			continue
+		}
+		switch {
+		case p == 0:
+			s = sgr{}
+		case p == 1:
+			s.bold = true
+		case p == 2:
+			s.faint = true
+		case p == 3:
+			s.italic = true
+		case p == 4:
+			s.underline = true
+		case p == 7:
+			s.reverse = true
+		case p == 9:
+			s.strike = true
+		case p == 22:
+			s.bold, s.faint = false, false
+		case p == 23:
+			s.italic = false
+		case p == 24:
+			s.underline = false
+		case p == 27:
+			s.reverse = false
+		case p == 29:
+			s.strike = false
+		case 30 <= p && p <= 37:
+			s.fg = strconv.Itoa(p - 30)
+		case 90 <= p && p <= 97:
+			s.fg = strconv.Itoa(p - 90 + 8)
+		case p == 39:
+			s.fg = ""
+		case 40 <= p && p <= 47:
+			s.bg = strconv.Itoa(p - 40)
+		case 100 <= p && p <= 107:
+			s.bg = strconv.Itoa(p - 100 + 8)
+		case p == 49:
+			s.bg = ""
+		case p == 38 || p == 48:
+			var c string
+			c, i = extendedColor(fields, i)
+			if p == 38 {
+				s.fg = c
+			} else {
+				s.bg = c
+			}
+		}
+	}
+	return s
+}
+
+// extendedColor parses the 256-color (5;n) or truecolor (2;r;g;b) color
+// following fields[i], which is 38 or 48.
+// It returns the color, or "" if it is malformed,
+// and the index of the last field it used.
+func extendedColor(fields []string, i int) (string, int) {
+	arg := func(j int) int {
+		if i+j >= len(fields) {
+			return -1
+		}
+		n, err := strconv.Atoi(fields[i+j])
+		if err != nil || n > 255 {
+			return -1
+		}
+		return n
+	}
+	switch arg(1) {
+	case 5:
+		if n := arg(2); n >= 0 {
+			return strconv.Itoa(n), i + 2
+		}
+		return "", i + 2
+	case 2:
+		r, g, b := arg(2), arg(3), arg(4)
+		if r >= 0 && g >= 0 && b >= 0 {
+			return fmt.Sprintf("#%02x%02x%02x", r, g, b), i + 4
+		}
+		return "", i + 4
+	}
+	return "", i + 1
+}
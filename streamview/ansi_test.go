@@ -0,0 +1,82 @@
+package streamview
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseANSI(t *testing.T) {
+	red, boldRed := sgr{fg: "1"}, sgr{fg: "1", bold: true}
+	for _, tt := range []struct {
+		in   string
+		want []span
+	}{
+		{"plain", []span{{text: "plain"}}},
+		{"", []span{{text: ""}}},
+		{"\x1b[31mred\x1b[0m", []span{{text: "red", style: red}}},
+		{"a\x1b[31mb\x1b[1mc\x1b[mD", []span{{text: "a"}, {text: "b", style: red}, {text: "c", style: boldRed}, {text: "D"}}},
+		{"\x1b[2Jclear\x1b[H", []span{{text: "clear"}}},
+		{"\x1b]0;title\atext", []span{{text: "text"}}},
+		{"\x1b]8;;http://x\x1b\\link", []span{{text: "link"}}},
+		{"\x1b(Bcharset", []span{{text: "charset"}}},
+		{"cut off\x1b[3", []span{{text: "cut off"}}},
+	} {
+		if got := parseANSI(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("parseANSI(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeLen(t *testing.T) {
+	for _, tt := range []struct {
+		in     string
+		n      int
+		params string
+		final  byte
+	}{
+		{"\x1b", 1, "", 0},
+		{"\x1b[1;31mx", 7, "1;31", 'm'},
+		{"\x1b[mx", 3, "", 'm'},
+		{"\x1b[?25lx", 6, "?25", 'l'},
+		{"\x1b[12", 4, "", 0},
+		{"\x1b]0;title\ax", 10, "", 0},
+		{"\x1b]0;title\x1b\\x", 11, "", 0},
+		{"\x1b]0;title", 9, "", 0},
+		{"\x1b(Bx", 3, "", 0},
+		{"\x1b=x", 2, "", 0},
+		{"\x1b(", 2, "", 0},
+	} {
+		n, params, final := escapeLen(tt.in)
+		if n != tt.n || params != tt.params || final != tt.final {
+			t.Errorf("escapeLen(%q) = %d, %q, %q, want %d, %q, %q", tt.in, n, params, final, tt.n, tt.params, tt.final)
+		}
+	}
+}
+
+func TestSGRApply(t *testing.T) {
+	bold := sgr{bold: true, fg: "2"}
+	for _, tt := range []struct {
+		from   sgr
+		params string
+		want   sgr
+	}{
+		{bold, "", sgr{}},
+		{bold, "0", sgr{}},
+		{sgr{}, "1;2;3;4;7;9", sgr{bold: true, faint: true, italic: true, underline: true, reverse: true, strike: true}},
+		{sgr{bold: true, faint: true, italic: true}, "22;23", sgr{}},
+		{sgr{}, "31;42", sgr{fg: "1", bg: "2"}},
+		{sgr{}, "91;107", sgr{fg: "9", bg: "15"}},
+		{sgr{fg: "1", bg: "2"}, "39;49", sgr{}},
+		{sgr{}, "38;5;208", sgr{fg: "208"}},
+		{sgr{}, "38:5:208", sgr{fg: "208"}},
+		{sgr{}, "48;2;255;0;16", sgr{bg: "#ff0010"}},
+		{sgr{}, "38;5;300;1", sgr{bold: true}},
+		{sgr{}, "38;2;1;2", sgr{}},
+		{bold, "x;4", sgr{bold: true, fg: "2", underline: true}},
+		{bold, "1;0;3", sgr{italic: true}},
+	} {
+		if got := tt.from.apply(tt.params); got != tt.want {
+			t.Errorf("%+v.apply(%q) = %+v, want %+v", tt.from, tt.params, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package streamview
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/josharian/pex/stream"
+	"github.com/muesli/reflow/truncate"
+)
+
+// renderFrame renders contents with style, like style.Render,
+// but with title and status embedded in the top border and footer in the bottom border,
+// if there are such borders.
+func renderFrame(style lipgloss.Style, contents, title, status, footer string) string {
+	top := (title != "" || status != "") && style.GetBorderTop()
+	bottom := footer != "" && style.GetBorderBottom()
+	box := style.Copy()
+	if top {
+		box = box.BorderTop(false)
+	}
+	if bottom {
+		box = box.BorderBottom(false)
+	}
+	s := box.Render(contents)
+	b := style.GetBorderStyle()
+	width := lipgloss.Width(s)
+	if top {
+		room := width - lipgloss.Width(b.TopLeft) - lipgloss.Width(b.TopRight) - borderPadding
+		text := titleText(title, status, room)
+		s = borderLine(b.TopLeft, b.Top, b.TopRight, text, width, style.GetBorderTopForeground()) + "\n" + s
+	}
+	if bottom {
+		s += "\n" + borderLine(b.BottomLeft, b.Bottom, b.BottomRight, footer, width, style.GetBorderBottomForeground())
+	}
+	return s
+}
+
+// titleText joins title and status, as in "grep foo · exit 1",
+// truncating title rather than status to fit in width cells.
+func titleText(title, status string, width int) string {
+	switch {
+	case status == "":
+		return title
+	case title == "":
+		return status
+	}
+	status = " · " + status
+	room := width - lipgloss.Width(status)
+	if room < 1 {
+		// Let borderLine truncate it all.
+		return title + status
+	}
+	return truncateTail(title, room) + status
+}
+
+// truncateTail truncates s to width cells, ending it with "…" if it doesn't fit.
+// Unlike truncate.StringWithTail, it leaves s alone if it fits exactly.
+func truncateTail(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	return truncate.StringWithTail(s, uint(width), "…")
+}
+
+// borderPadding is the number of cells around text in a border line:
+// a fill character and a space on each side.
+const borderPadding = 4
+
+// borderLine renders a horizontal border line of the given width,
+// with text embedded near its left end, truncated as necessary.
+// The text may be styled.
+func borderLine(left, fill, right, text string, width int, fg lipgloss.TerminalColor) string {
+	border := lipgloss.NewStyle().Foreground(fg)
+	inner := width - lipgloss.Width(left) - lipgloss.Width(right)
+	if inner <= 0 {
+		return border.Render(left + right)
+	}
+	var line string
+	if room := inner - borderPadding; room > 0 && text != "" {
+		text = truncateTail(text, room)
+		line = border.Render(left+fill) + " " + text + " "
+	} else {
+		line = border.Render(left)
+	}
+	rest := width - lipgloss.Width(line) - lipgloss.Width(right)
+	return line + border.Render(strings.Repeat(fill, max(0, rest))+right)
+}
+
+// statsText summarizes st for display, for example "1,204 lines · 88 KB · done".
+func statsText(st stream.Stats) string {
+	lines := formatCount(st.Lines) + " lines"
+	if st.Lines == 1 {
+		lines = "1 line"
+	}
+	parts := []string{lines, formatBytes(st.Bytes)}
+	switch {
+	case st.Err == io.EOF:
+		parts = append(parts, "done")
+	case st.Err != nil:
+		parts = append(parts, "error: "+st.Err.Error())
+	case st.BytesPerSec() > 0:
+		parts = append(parts, formatBytes(int(st.BytesPerSec()))+"/s")
+	}
+	return strings.Join(parts, " · ")
+}
+
+// formatCount formats n with thousands separators.
+func formatCount(n int) string {
+	if n < 0 {
+		return "-" + formatCount(-n)
+	}
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// formatBytes formats n as a human-readable size, such as "88 KB".
+func formatBytes(n int) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	units := []string{"KB", "MB", "GB", "TB"}
+	f := float64(n) / 1024
+	i := 0
+	for f >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	if f < 10 {
+		return fmt.Sprintf("%.1f %s", f, units[i])
+	}
+	return fmt.Sprintf("%.0f %s", f, units[i])
+}
@@ -0,0 +1,131 @@
+package streamview
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/josharian/pex/stream"
+)
+
+func TestTitleText(t *testing.T) {
+	for _, tt := range []struct {
+		title, status string
+		width         int
+		want          string
+	}{
+		{"grep foo", "", 20, "grep foo"},
+		{"", "exit 1", 20, "exit 1"},
+		{"grep foo", "exit 1", 20, "grep foo · exit 1"},
+		{"grep", "exit 1", 13, "grep · exit 1"},        // just fits
+		{"grep foo bar", "exit 1", 12, "gr… · exit 1"}, // the title is truncated, not the status
+		{"grep foo", "exit 1", 5, "grep foo · exit 1"}, // no room for any title; left to borderLine
+	} {
+		if got := titleText(tt.title, tt.status, tt.width); got != tt.want {
+			t.Errorf("titleText(%q, %q, %d) = %q, want %q", tt.title, tt.status, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestBorderLine(t *testing.T) {
+	for _, tt := range []struct {
+		text  string
+		width int
+		want  string
+	}{
+		{"title", 20, "╭─ title ──────────╮"},
+		{"title", 11, "╭─ title ─╮"}, // just fits
+		{"a long title", 10, "╭─ a l… ─╮"},
+		{"", 5, "╭───╮"},
+		{"title", 5, "╭───╮"}, // no room for text
+		{"title", 1, "╭╮"},
+	} {
+		got := plainText(borderLine("╭", "─", "╮", tt.text, tt.width, lipgloss.NoColor{}))
+		if got != tt.want {
+			t.Errorf("borderLine(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestRenderFrame(t *testing.T) {
+	style := lipgloss.NewStyle().Border(lipgloss.RoundedBorder())
+	want := strings.Join([]string{
+		"╭─ ls · exit 0 ─╮",
+		"│abcdefghijklmno│",
+		"╰─ 1 line ──────╯",
+	}, "\n")
+	if got := plainText(renderFrame(style, "abcdefghijklmno", "ls", "exit 0", "1 line")); got != want {
+		t.Errorf("renderFrame = \n%s\nwant\n%s", got, want)
+	}
+	// Without text, the borders are as usual.
+	if got, want := renderFrame(style, "abc", "", "", ""), style.Render("abc"); got != want {
+		t.Errorf("renderFrame without text = \n%s\nwant\n%s", got, want)
+	}
+	// Without borders, there is nowhere to put the text.
+	plain := lipgloss.NewStyle()
+	if got, want := renderFrame(plain, "abc", "ls", "exit 0", "1 line"), plain.Render("abc"); got != want {
+		t.Errorf("renderFrame without borders = %q, want %q", got, want)
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	for _, tt := range []struct {
+		n    int
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{123456, "123,456"},
+		{1234567, "1,234,567"},
+		{-1234, "-1,234"},
+	} {
+		if got := formatCount(tt.n); got != tt.want {
+			t.Errorf("formatCount(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for _, tt := range []struct {
+		n    int
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{88 << 10, "88 KB"},
+		{5 << 20, "5.0 MB"},
+		{300 << 30, "300 GB"},
+		{2048 << 40, "2048 TB"}, // there is no bigger unit
+	} {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestStatsText(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		st   stream.Stats
+		want string
+	}{
+		{stream.Stats{}, "0 lines · 0 B"},
+		{stream.Stats{Lines: 1, Bytes: 6}, "1 line · 6 B"},
+		{stream.Stats{Lines: 1204, Bytes: 88 << 10, Err: io.EOF}, "1,204 lines · 88 KB · done"},
+		{stream.Stats{Lines: 3, Bytes: 30, Err: errors.New("broken pipe")}, "3 lines · 30 B · error: broken pipe"},
+		{
+			stream.Stats{Lines: 10, Bytes: 4 << 10, First: start, Last: start.Add(2 * time.Second)},
+			"10 lines · 4.0 KB · 2.0 KB/s",
+		},
+	} {
+		if got := statsText(tt.st); got != tt.want {
+			t.Errorf("statsText(%+v) = %q, want %q", tt.st, got, tt.want)
+		}
+	}
+}
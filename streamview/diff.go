@@ -0,0 +1,520 @@
+package streamview
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync/atomic"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/josharian/pex/stream"
+)
+
+// diffState is the state of a diff of the stream against a base stream,
+// typically the input of the command whose output is the stream.
+//
+// Both streams may still be growing, so the diff is computed incrementally,
+// in the background, a window of lines at a time.
+// Once a diff reaches a line common to both streams,
+// everything up to that line is settled, and later diffs start after it.
+// The rest of the diff is shown, but is recomputed as more lines arrive.
+type diffState struct {
+	base    *stream.Shared // nil if not diffing
+	id      uint64         // distinguishes this diff's results from stale ones
+	lines   []diffLine
+	settled int  // lines[:settled] will not change
+	a, b    int  // lines of the base stream and this stream covered by lines[:settled]
+	sizeA   int  // base buffer length when last diffed
+	sizeB   int  // buffer length when last diffed
+	endedA  bool // the base stream had ended when last diffed
+	endedB  bool // the stream had ended when last diffed
+	more    bool // there are lines left to diff right away
+	running bool // a diffCmd is in flight
+
+	added, removed        int // counts of lines
+	settledAdd, settledRm int // counts of lines in lines[:settled]
+}
+
+// diffOp is how a line of a diff relates the two streams.
+type diffOp int8
+
+const (
+	diffSame    diffOp = iota // in both streams
+	diffAdded                 // only in this stream
+	diffRemoved               // only in the base stream
+)
+
+// diffLine is a line of a diff.
+type diffLine struct {
+	op diffOp
+	// a and b are the line's numbers in the base stream and this stream.
+	// Added and removed lines are missing from one of the streams;
+	// for that stream, they hold the number of the next line.
+	a, b int
+	// pair is the number of a changed line's counterpart in the other stream:
+	// the removed line that an added line replaced, or vice versa.
+	// It is -1 for unchanged lines, and lines that are purely added or removed.
+	pair int
+}
+
+var diffID atomic.Uint64
+
+// diffWindow is the most lines of each stream that one diffCmd diffs.
+// It bounds the diff's time and memory, which grow quadratically
+// with the number of differences.
+const diffWindow = 500
+
+type diffMsg struct {
+	id             uint64
+	lines          []diffLine // from the end of the settled lines on
+	settle         int        // how many of lines are now settled
+	a, b           int        // lines of each stream covered by the newly settled lines
+	sizeA, sizeB   int
+	endedA, endedB bool
+	more           bool
+}
+
+// baseChangedMsg reports that the base stream of a diff has changed.
+type baseChangedMsg struct {
+	id uint64 // of the diff
+}
+
+// diffCmd diffs the streams from the end of the settled lines.
+func diffCmd(m *Model) tea.Cmd {
+	d := &m.diff
+	d.running = true
+	base, shared := d.base, m.shared
+	bufA, bufB := base.Text(), m.buffer()
+	id, a0, b0 := d.id, d.a, d.b
+	return func() tea.Msg {
+		// Check whether the streams have ended first, so that
+		// if they have, all their lines are counted.
+		endedA, endedB := base.Err() != nil, shared.Err() != nil
+		sizeA, sizeB := bufA.Len(), bufB.Len()
+		msg := diffStep(bufSide(bufA, a0, endedA), bufSide(bufB, b0, endedB))
+		msg.id, msg.sizeA, msg.sizeB, msg.endedA, msg.endedB = id, sizeA, sizeB, endedA, endedB
+		return msg
+	}
+}
+
+// diffSide is the window of one of the streams that a diffCmd diffs.
+type diffSide struct {
+	lines []string // at most diffWindow lines
+	start int      // number of lines[0] in the stream
+	more  bool     // the stream has complete lines past lines
+	ended bool     // the stream has ended
+}
+
+// bufSide returns the window of buf starting at line from.
+func bufSide(buf *stream.Buffer, from int, ended bool) diffSide {
+	n := completeLines(buf, ended)
+	to := min(n, from+diffWindow)
+	return diffSide{lines: bufLines(buf, from, to), start: from, more: to < n, ended: ended}
+}
+
+// done reports whether s reaches the end of its stream, for good.
+func (s diffSide) done() bool { return s.ended && !s.more }
+
+// full reports whether s was cut short by the window size.
+func (s diffSide) full() bool { return len(s.lines) == diffWindow }
+
+// diffStep diffs the windows x of the base stream and y of this stream.
+func diffStep(x, y diffSide) diffMsg {
+	lines := diffLines(myers(x.lines, y.lines), x.start, y.start)
+	pairChanges(lines, x.lines, y.lines, x.start, y.start)
+
+	msg := diffMsg{lines: lines}
+	// Settle up to the last line in common.
+	for j := len(lines) - 1; j >= 0; j-- {
+		if lines[j].op == diffSame {
+			msg.settle = j + 1
+			break
+		}
+	}
+	switch {
+	case x.done() && y.done():
+		// There will be no more lines.
+		msg.settle = len(lines)
+	case msg.settle == 0 && (x.done() || x.full()) && (y.done() || y.full()):
+		// Nothing in common in this window, and more lines won't help,
+		// because neither side can get any more lines into it.
+		// Give up on it, to get on with the rest.
+		// (A side that is still growing into its window might yet have
+		// lines in common, so for that, wait.)
+		msg.settle = len(lines)
+	}
+	msg.a, msg.b = x.start, y.start
+	if msg.settle > 0 {
+		last := lines[msg.settle-1]
+		msg.a, msg.b = last.a, last.b
+		if last.op != diffAdded {
+			msg.a++
+		}
+		if last.op != diffRemoved {
+			msg.b++
+		}
+	}
+	msg.more = msg.settle > 0 && (x.more || y.more)
+	return msg
+}
+
+// completeLines returns the number of lines in buf that won't grow any more.
+func completeLines(buf *stream.Buffer, ended bool) int {
+	n := buf.NLines()
+	if !ended && n > 0 {
+		// The last line may be incomplete.
+		n--
+	}
+	return n
+}
+
+// bufLines returns lines [from, to) of buf.
+func bufLines(buf *stream.Buffer, from, to int) []string {
+	lines := make([]string, 0, max(0, to-from))
+	for i := from; i < to; i++ {
+		lines = append(lines, buf.Line(i))
+	}
+	return lines
+}
+
+// myers returns the shortest edit script turning x into y,
+// using Myers' O(ND) diff algorithm.
+func myers(x, y []string) []diffOp {
+	n, m := len(x), len(y)
+	off := n + m + 1
+	v := make([]int, 2*off+1) // v[off+k] is the furthest x reached on diagonal k
+	var trace [][]int         // trace[d] is v[off-d-1 : off+d+2] before step d
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, slices.Clone(v[off-d-1:off+d+2]))
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || k != d && v[off+k-1] < v[off+k+1] {
+				i = v[off+k+1] // down: insert y[j]
+			} else {
+				i = v[off+k-1] + 1 // right: delete x[i]
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+			v[off+k] = i
+			if i >= n && j >= m {
+				return backtrack(trace, n, m)
+			}
+		}
+	}
+	return nil // unreachable
+}
+
+// backtrack recovers the edit script from the trace left by myers.
+func backtrack(trace [][]int, i, j int) []diffOp {
+	var ops []diffOp
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+		k := i - j
+		var pk int
+		if k == -d || k != d && at(k-1) < at(k+1) {
+			pk = k + 1
+		} else {
+			pk = k - 1
+		}
+		pi := at(pk)
+		pj := pi - pk
+		for i > pi && j > pj {
+			ops = append(ops, diffSame)
+			i--
+			j--
+		}
+		if d > 0 {
+			if i == pi {
+				ops = append(ops, diffAdded)
+			} else {
+				ops = append(ops, diffRemoved)
+			}
+		}
+		i, j = pi, pj
+	}
+	slices.Reverse(ops)
+	return ops
+}
+
+// diffLines numbers the lines of the edit script ops,
+// which starts at line a of the base stream and line b of this stream.
+func diffLines(ops []diffOp, a, b int) []diffLine {
+	lines := make([]diffLine, len(ops))
+	for j, op := range ops {
+		lines[j] = diffLine{op: op, a: a, b: b, pair: -1}
+		if op != diffAdded {
+			a++
+		}
+		if op != diffRemoved {
+			b++
+		}
+	}
+	return lines
+}
+
+// pairChanges pairs up the removed and added lines in each run of changes,
+// for intraline highlighting.
+// Each added line is paired with the most similar removed line, keeping them in order.
+func pairChanges(lines []diffLine, x, y []string, a0, b0 int) {
+	for j := 0; j < len(lines); {
+		if lines[j].op == diffSame {
+			j++
+			continue
+		}
+		var rm, add []int
+		for ; j < len(lines) && lines[j].op != diffSame; j++ {
+			if lines[j].op == diffRemoved {
+				rm = append(rm, j)
+			} else {
+				add = append(add, j)
+			}
+		}
+		next := 0 // first removed line that may still be paired
+		for _, ai := range add {
+			added := y[lines[ai].b-b0]
+			best, score := -1, 0
+			for r := next; r < len(rm); r++ {
+				start, end := changedRange(added, x[lines[rm[r]].a-a0])
+				if sc := start + len(added) - end; sc > score {
+					best, score = r, sc
+				}
+			}
+			if best < 0 {
+				continue
+			}
+			lines[ai].pair, lines[rm[best]].pair = lines[rm[best]].a, lines[ai].b
+			next = best + 1
+		}
+	}
+}
+
+// SetDiff starts showing the stream as a diff against base, replacing any previous diff.
+// A nil base stops showing a diff.
+func (m *Model) SetDiff(base *stream.Shared) tea.Cmd {
+	line := m.bufferLine(m.CurrentLine)
+	m.diff = diffState{base: base, id: diffID.Add(1)}
+	if base == nil {
+		// Stay on the same line of the stream.
+		m.SetCurrentLine(line)
+		return nil
+	}
+	m.SetCurrentLine(0)
+	cmd := diffCmd(m)
+	if base.Err() == nil {
+		cmd = tea.Batch(cmd, watchCmd(m.ctx, base, baseChangedMsg{id: m.diff.id}))
+	}
+	return cmd
+}
+
+// Diffing reports whether the stream is shown as a diff.
+func (m Model) Diffing() bool {
+	return m.diff.base != nil
+}
+
+// diffing reports whether the stream is being shown as a diff right now.
+func (m Model) diffing() bool {
+	return m.Diffing() && !m.hex()
+}
+
+// diffMore continues the diff if there is more to diff and no diff running.
+func (m *Model) diffMore() tea.Cmd {
+	d := &m.diff
+	if d.base == nil || d.running {
+		return nil
+	}
+	if !d.more && d.base.Text().Len() == d.sizeA && m.buffer().Len() == d.sizeB &&
+		(d.base.Err() != nil) == d.endedA && (m.shared.Err() != nil) == d.endedB {
+		return nil
+	}
+	return diffCmd(m)
+}
+
+// updateDiff incorporates the results of a diffCmd.
+func (m *Model) updateDiff(msg diffMsg) tea.Cmd {
+	d := &m.diff
+	if msg.id != d.id {
+		return nil
+	}
+	d.apply(msg)
+	// Recomputing the unsettled lines may have shortened the diff.
+	m.CurrentLine = min(m.CurrentLine, m.maxLine())
+	if m.Follow {
+		m.showEnd()
+	}
+	return m.diffMore()
+}
+
+// apply incorporates msg into the diff.
+func (d *diffState) apply(msg diffMsg) {
+	d.running = false
+	d.lines = append(d.lines[:d.settled], msg.lines...)
+	add, rm := countChanges(msg.lines[:msg.settle])
+	d.settledAdd += add
+	d.settledRm += rm
+	add, rm = countChanges(msg.lines[msg.settle:])
+	d.added, d.removed = d.settledAdd+add, d.settledRm+rm
+	d.settled += msg.settle
+	d.a, d.b = msg.a, msg.b
+	d.sizeA, d.sizeB, d.more = msg.sizeA, msg.sizeB, msg.more
+	d.endedA, d.endedB = msg.endedA, msg.endedB
+}
+
+func countChanges(lines []diffLine) (added, removed int) {
+	for _, l := range lines {
+		switch l.op {
+		case diffAdded:
+			added++
+		case diffRemoved:
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// bufferLine returns the number in the stream of line i of the view,
+// which differ when showing a diff.
+// For lines removed from the base stream, it is the number of the next line.
+func (m Model) bufferLine(i int) int {
+	if !m.diffing() {
+		return i
+	}
+	return m.diffLine(i).b
+}
+
+// diffLine returns line i of the diff.
+// Past either end, it returns an unchanged line after the end of the stream,
+// which shows up blank.
+func (m Model) diffLine(i int) diffLine {
+	if i < 0 || i >= len(m.diff.lines) {
+		return diffLine{op: diffSame, a: m.diff.base.Text().NLines(), b: m.buffer().NLines(), pair: -1}
+	}
+	return m.diff.lines[i]
+}
+
+// viewLine returns the line of the view that shows line n of the stream.
+// When showing a diff, that may be past the end of the diff so far.
+func (m Model) viewLine(n int) int {
+	if !m.diffing() {
+		return n
+	}
+	lines := m.diff.lines
+	return sort.Search(len(lines), func(i int) bool {
+		return lines[i].b > n || lines[i].b == n && lines[i].op != diffRemoved
+	})
+}
+
+var (
+	addedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	removedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+)
+
+// diffGutters returns the +/- markers for the given lines of a diff.
+func (m Model) diffGutters(lines []int) []string {
+	text := make([]string, len(lines))
+	for j, i := range lines {
+		switch m.diffLine(i).op {
+		case diffAdded:
+			text[j] = addedStyle.Render("+")
+		case diffRemoved:
+			text[j] = removedStyle.Render("-")
+		default:
+			text[j] = " "
+		}
+	}
+	return text
+}
+
+// diffSpans styles spans, the text of line i of a diff, by how it changed.
+// Added lines are green. Removed lines are faint red ghosts.
+// In changed lines, the part that changed is highlighted too.
+func (m Model) diffSpans(spans []span, i int) []span {
+	l := m.diffLine(i)
+	var line, other func(sgr) sgr
+	switch l.op {
+	case diffSame:
+		return spans
+	case diffAdded:
+		line = func(s sgr) sgr { s.fg = "2"; return s }
+		other = func(s sgr) sgr { s.bg = "22"; return s }
+	case diffRemoved:
+		line = func(s sgr) sgr { s.fg, s.faint = "1", true; return s }
+		other = func(s sgr) sgr { s.bg = "52"; return s }
+	}
+	text := ""
+	for j := range spans {
+		spans[j].style = line(spans[j].style)
+		text += spans[j].text
+	}
+	if l.pair < 0 {
+		return spans
+	}
+	var counterpart string
+	if l.op == diffAdded {
+		counterpart = m.diff.base.Text().Line(l.pair)
+	} else {
+		counterpart = m.buffer().Line(l.pair)
+	}
+	start, end := changedRange(text, plainText(counterpart))
+	if start == 0 && end == len(text) {
+		// Nothing in common; highlighting it all would just be noise.
+		return spans
+	}
+	return restyle(spans, start, end, other)
+}
+
+// changedRange returns the byte range of s that differs from t,
+// found by trimming their common prefix and suffix.
+func changedRange(s, t string) (start, end int) {
+	p := 0
+	for p < len(s) && p < len(t) && s[p] == t[p] {
+		p++
+	}
+	for p > 0 && p < len(s) && !utf8.RuneStart(s[p]) {
+		p--
+	}
+	q := 0
+	for q < len(s)-p && q < len(t)-p && s[len(s)-1-q] == t[len(t)-1-q] {
+		q++
+	}
+	for q > 0 && !utf8.RuneStart(s[len(s)-q]) {
+		q--
+	}
+	return p, len(s) - q
+}
+
+// restyle applies f to the style of the parts of spans
+// in the byte range [start, end) of their concatenated text.
+func restyle(spans []span, start, end int, f func(sgr) sgr) []span {
+	var out []span
+	off := 0
+	for _, sp := range spans {
+		lo, hi := clamp(start-off, 0, len(sp.text)), clamp(end-off, 0, len(sp.text))
+		off += len(sp.text)
+		if lo >= hi {
+			out = append(out, sp)
+			continue
+		}
+		if lo > 0 {
+			out = append(out, span{text: sp.text[:lo], style: sp.style})
+		}
+		out = append(out, span{text: sp.text[lo:hi], style: f(sp.style)})
+		if hi < len(sp.text) {
+			out = append(out, span{text: sp.text[hi:], style: sp.style})
+		}
+	}
+	return out
+}
+
+// diffText summarizes the diff for the footer, such as "diff +3 -2".
+func (m Model) diffText() string {
+	if !m.diffing() {
+		return ""
+	}
+	return fmt.Sprintf("diff +%d -%d", m.diff.added, m.diff.removed)
+}
@@ -0,0 +1,190 @@
+package streamview
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMyers(t *testing.T) {
+	for _, tt := range []struct {
+		x, y string
+	}{
+		{"", ""},
+		{"a b c", "a b c"},
+		{"a b c", ""},
+		{"", "x y"},
+		{"a b", "b a"},
+		{"a b c a b b a", "c b a b a c"},
+		{"a b c d e f", "a x c d y f z"},
+		{"a a a", "a a"},
+	} {
+		x, y := strings.Fields(tt.x), strings.Fields(tt.y)
+		ops := myers(x, y)
+		var got []string
+		i, j, changes := 0, 0, 0
+		for _, op := range ops {
+			switch op {
+			case diffSame:
+				if i >= len(x) || j >= len(y) || x[i] != y[j] {
+					t.Fatalf("myers(%q, %q) = %v: bad same line", tt.x, tt.y, ops)
+				}
+				got = append(got, x[i])
+				i++
+				j++
+			case diffRemoved:
+				i++
+				changes++
+			case diffAdded:
+				got = append(got, y[j])
+				j++
+				changes++
+			}
+		}
+		if i != len(x) || !slices.Equal(got, y) {
+			t.Errorf("myers(%q, %q) = %v, which turns x into %q", tt.x, tt.y, ops, got)
+		}
+		if want := len(x) + len(y) - 2*lcs(x, y); changes != want {
+			t.Errorf("myers(%q, %q) = %v: got %d changes, want %d", tt.x, tt.y, ops, changes, want)
+		}
+	}
+}
+
+// lcs returns the length of the longest common subsequence of x and y.
+func lcs(x, y []string) int {
+	prev, cur := make([]int, len(y)+1), make([]int, len(y)+1)
+	for i := range x {
+		for j := range y {
+			if x[i] == y[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(y)]
+}
+
+func TestChangedRange(t *testing.T) {
+	for _, tt := range []struct {
+		s, t       string
+		start, end int
+	}{
+		{"abc", "abc", 3, 3},
+		{"abXc", "abc", 2, 3},
+		{"x", "", 0, 1},
+		{"aa", "a", 1, 2},
+		{"héllo", "hallo", 1, 3},
+		{"日本", "日木", 3, 6},
+	} {
+		start, end := changedRange(tt.s, tt.t)
+		if start != tt.start || end != tt.end {
+			t.Errorf("changedRange(%q, %q) = %d, %d, want %d, %d", tt.s, tt.t, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestPairChanges(t *testing.T) {
+	x := []string{"same", "foo = 1", "bar = 2", "baz"}
+	y := []string{"same", "bar = 3", "qux"}
+	lines := diffLines(myers(x, y), 10, 20)
+	pairChanges(lines, x, y, 10, 20)
+	// Line 21, "bar = 3", replaced line 12, "bar = 2". Nothing else pairs up.
+	for _, l := range lines {
+		want := -1
+		switch {
+		case l.op == diffAdded && l.b == 21:
+			want = 12
+		case l.op == diffRemoved && l.a == 12:
+			want = 21
+		}
+		if l.pair != want {
+			t.Errorf("%+v: got pair %d, want %d", l, l.pair, want)
+		}
+	}
+}
+
+// side returns the window of lines starting at line from, like bufSide.
+func side(lines []string, from int, ended bool) diffSide {
+	to := min(len(lines), from+diffWindow)
+	return diffSide{lines: lines[from:to], start: from, more: to < len(lines), ended: ended}
+}
+
+// runDiff diffs b against a as far as it can without more lines, like diffMore.
+func runDiff(d *diffState, a, b []string, endedA, endedB bool) {
+	for {
+		msg := diffStep(side(a, d.a, endedA), side(b, d.b, endedB))
+		d.apply(msg)
+		if !msg.more {
+			return
+		}
+	}
+}
+
+// checkDiff checks that d is a complete, settled diff of b against a.
+func checkDiff(t *testing.T, d *diffState, a, b []string) {
+	t.Helper()
+	if d.settled != len(d.lines) {
+		t.Errorf("settled %d of %d lines", d.settled, len(d.lines))
+	}
+	var gotA, gotB []string
+	for _, l := range d.lines {
+		if l.op != diffAdded {
+			gotA = append(gotA, a[l.a])
+		}
+		if l.op != diffRemoved {
+			gotB = append(gotB, b[l.b])
+		}
+	}
+	if !slices.Equal(gotA, a) || !slices.Equal(gotB, b) {
+		t.Errorf("diff does not cover the streams: got %d and %d lines, want %d and %d", len(gotA), len(gotB), len(a), len(b))
+	}
+	add, rm := countChanges(d.lines)
+	if add != d.added || rm != d.removed {
+		t.Errorf("counts: got +%d -%d, want +%d -%d", d.added, d.removed, add, rm)
+	}
+}
+
+func numbered(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprint("line ", i)
+	}
+	return lines
+}
+
+func TestDiffFilter(t *testing.T) {
+	// A filter's output has nothing in common with most windows of its input.
+	a, b := numbered(2000), []string{"line 3", "line 50"}
+	var d diffState
+	runDiff(&d, a, b, true, true)
+	checkDiff(t, &d, a, b)
+	if d.added != 0 || d.removed != 1998 {
+		t.Errorf("got +%d -%d, want +0 -1998", d.added, d.removed)
+	}
+}
+
+func TestDiffIncremental(t *testing.T) {
+	// The input has all arrived, but the filter's output is lagging behind.
+	a := numbered(2000)
+	var b []string
+	for i := 0; i < len(a); i += 10 {
+		b = append(b, a[i])
+	}
+	var d diffState
+	var settled []diffLine
+	for n := 0; n <= len(b); n += 7 {
+		runDiff(&d, a, b[:n], true, false)
+		if !slices.Equal(d.lines[:len(settled)], settled) {
+			t.Fatalf("after %d lines, settled lines changed", n)
+		}
+		settled = slices.Clone(d.lines[:d.settled])
+	}
+	runDiff(&d, a, b, true, true)
+	checkDiff(t, &d, a, b)
+	if d.added != 0 || d.removed != 1800 {
+		t.Errorf("got +%d -%d, want +0 -1800", d.added, d.removed)
+	}
+}
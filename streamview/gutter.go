@@ -0,0 +1,134 @@
+package streamview
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// TimeMode selects what a time gutter shows.
+type TimeMode int
+
+const (
+	TimeOff     TimeMode = iota // no time gutter
+	TimeElapsed                 // time since the stream's first data, e.g. +12.345s
+	TimeDelta                   // time since the previous line, e.g. +0.312s
+	TimeClock                   // wall clock time, e.g. 15:04:05.000
+)
+
+// Next returns the mode after t, cycling back to TimeOff.
+func (t TimeMode) Next() TimeMode {
+	return (t + 1) % (TimeClock + 1)
+}
+
+// stallThreshold is the delay between lines that is highlighted in time gutters.
+const stallThreshold = time.Second
+
+var (
+	gutterStyle = lipgloss.NewStyle().Faint(true)
+	stallStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("3")) // yellow
+)
+
+// gutters returns the gutter for each of the given lines, padded to equal width,
+// or nil if there are no gutters.
+// Discarded lines (line numbers less than first) get blank gutters.
+func (m Model) gutters(lines []int, first int) []string {
+	var cols [][]string
+	if m.diffing() {
+		cols = append(cols, m.diffGutters(lines))
+	}
+	if m.LineNumbers {
+		cols = append(cols, m.numberGutters(lines, first))
+	}
+	if g := m.timeGutters(lines, first); g != nil {
+		cols = append(cols, g)
+	}
+	if len(cols) == 0 {
+		return nil
+	}
+	gutters := make([]string, len(lines))
+	for j := range lines {
+		for _, col := range cols {
+			gutters[j] += col[j] + " "
+		}
+	}
+	return gutters
+}
+
+// numberGutters returns 1-based line numbers for the given lines,
+// wide enough for the number of lines in the stream so far.
+// In a diff, they are the numbers of lines in the stream,
+// and removed lines get blank gutters.
+func (m Model) numberGutters(lines []int, first int) []string {
+	n := m.nlines()
+	if m.diffing() {
+		n = m.buffer().NLines()
+	}
+	width := len(strconv.Itoa(n))
+	text := make([]string, len(lines))
+	for j, i := range lines {
+		if i < first || m.diffing() && m.diffLine(i).op == diffRemoved {
+			text[j] = strings.Repeat(" ", width)
+			continue
+		}
+		text[j] = gutterStyle.Render(fmt.Sprintf("%*d", width, m.bufferLine(i)+1))
+	}
+	return text
+}
+
+// timeGutters returns the time gutters for the given lines,
+// or nil if there are none.
+func (m Model) timeGutters(lines []int, first int) []string {
+	if m.TimeGutter == TimeOff || m.diffing() {
+		return nil
+	}
+	text := make([]string, len(lines))
+	stall := make([]bool, len(lines))
+	width := 0
+	for j, i := range lines {
+		if i < first {
+			continue
+		}
+		text[j], stall[j] = m.timeGutter(i)
+		width = max(width, len(text[j]))
+	}
+	if width == 0 {
+		// No timestamps were recorded.
+		return nil
+	}
+	for j := range text {
+		style := gutterStyle
+		if stall[j] {
+			style = stallStyle
+		}
+		text[j] = style.Render(fmt.Sprintf("%*s", width, text[j]))
+	}
+	return text
+}
+
+// timeGutter returns the time gutter text for line i,
+// and whether the line arrived after a stall.
+func (m Model) timeGutter(i int) (text string, stall bool) {
+	t, ok := m.buffer().LineTime(i)
+	if !ok {
+		return "", false
+	}
+	prev, ok := m.buffer().LineTime(i - 1)
+	if !ok {
+		prev = m.shared.Stats().First
+	}
+	delta := t.Sub(prev)
+	stall = delta >= stallThreshold
+	switch m.TimeGutter {
+	case TimeElapsed:
+		return fmt.Sprintf("+%.3fs", t.Sub(m.shared.Stats().First).Seconds()), stall
+	case TimeDelta:
+		return fmt.Sprintf("+%.3fs", delta.Seconds()), stall
+	case TimeClock:
+		return t.Format("15:04:05.000"), stall
+	}
+	return "", false
+}
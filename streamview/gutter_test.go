@@ -0,0 +1,59 @@
+package streamview
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/josharian/pex/stream"
+)
+
+// plainGutters returns gs without styling.
+func plainGutters(gs []string) []string {
+	out := make([]string, len(gs))
+	for i, g := range gs {
+		out[i] = plainText(g)
+	}
+	return out
+}
+
+func TestNumberGutters(t *testing.T) {
+	m := newTestModel(strings.Repeat("x\n", 12), 20, 5)
+	for _, tt := range []struct {
+		lines []int
+		first int
+		want  []string
+	}{
+		{[]int{0, 1, 2}, 0, []string{" 1", " 2", " 3"}},
+		{[]int{8, 9, 11}, 0, []string{" 9", "10", "12"}},
+		{[]int{0, 1, 2}, 2, []string{"  ", "  ", " 3"}}, // discarded lines
+	} {
+		if got := plainGutters(m.numberGutters(tt.lines, tt.first)); !slices.Equal(got, tt.want) {
+			t.Errorf("numberGutters(%v, %d) = %q, want %q", tt.lines, tt.first, got, tt.want)
+		}
+	}
+}
+
+func TestNumberGuttersDiff(t *testing.T) {
+	var a, b strings.Builder
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&a, "line %d\n", i)
+		if i != 3 {
+			fmt.Fprintf(&b, "line %d\n", i)
+		}
+	}
+	base := stream.NewShared(strings.NewReader(a.String()), stream.Options{})
+	io.ReadAll(base.Reader())
+	m := newTestModel(b.String(), 20, 5)
+	m.SetDiff(base)
+	m.updateDiff(diffCmd(&m)().(diffMsg))
+	// The diff has 10 lines, but the numbers are of the stream's 9 lines,
+	// and line 3 was removed.
+	want := []string{"1", "2", " ", "3", "4", "5", "6", "7", "8", "9"}
+	lines := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if got := plainGutters(m.numberGutters(lines, 0)); !slices.Equal(got, want) {
+		t.Errorf("numberGutters in a diff = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package streamview
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// HexMode selects whether a column is shown as a hex dump.
+type HexMode int
+
+const (
+	HexAuto HexMode = iota // hex dump if the stream looks binary
+	HexOff
+	HexOn
+)
+
+// hexRowSize is the number of bytes in each hex dump row.
+// In hex mode, rows take the place of lines.
+const hexRowSize = 16
+
+// sniffSize is how much of a stream is examined to decide whether it looks binary.
+const sniffSize = 8000
+
+// hex reports whether m is showing a hex dump.
+func (m Model) hex() bool {
+	switch m.Hex {
+	case HexOn:
+		return true
+	case HexOff:
+		return false
+	}
+	return m.binary
+}
+
+// ToggleHex switches m between a hex dump and text,
+// keeping the same part of the stream in view.
+func (m *Model) ToggleHex() tea.Cmd {
+	off, ok := m.TopOffset()
+	if m.hex() {
+		m.Hex = HexOff
+	} else {
+		m.Hex = HexOn
+	}
+	if ok {
+		if m.hex() {
+			m.SetCurrentLine(off / hexRowSize)
+		} else if line, ok := m.buffer().LineAt(m.textOffset(off)); ok {
+			m.SetCurrentLine(m.viewLine(line))
+		}
+	}
+	if m.shouldReadMore() {
+		return readCmd(m)
+	}
+	return nil
+}
+
+// sniff decides whether m's stream looks binary,
+// until it has seen enough of the stream to be sure.
+func (m *Model) sniff() {
+	if m.sniffed {
+		return
+	}
+	raw, text := m.shared.Buffer(), m.buffer()
+	p := make([]byte, sniffSize)
+	n, _ := raw.ReadAt(p, int64(raw.Start()))
+	m.binary = looksBinary(p[:n], text.Sep())
+	if m.binary && text != raw {
+		// The stream starts with a byte order mark.
+		// UTF-16 text is full of NULs, so the decoded text has to look binary too.
+		k, _ := text.ReadAt(p, int64(text.Start()))
+		m.binary = looksBinary(p[:k], text.Sep())
+	}
+	m.sniffed = n == sniffSize || m.shared.Err() != nil
+}
+
+// rawOffset converts an offset in the text of m's stream to one in its raw bytes,
+// and textOffset does the reverse.
+// They differ only for streams decoded because of a byte order mark.
+// For those, the conversion is proportional, which is close enough
+// to keep about the same part of the stream in view.
+func (m Model) rawOffset(off int) int {
+	raw, text := m.shared.Buffer(), m.buffer()
+	if raw == text || text.Len() == 0 {
+		return off
+	}
+	return int(int64(off) * int64(raw.Len()) / int64(text.Len()))
+}
+
+func (m Model) textOffset(off int) int {
+	raw, text := m.shared.Buffer(), m.buffer()
+	if raw == text || raw.Len() == 0 {
+		return off
+	}
+	return int(int64(off) * int64(text.Len()) / int64(raw.Len()))
+}
+
+// looksBinary reports whether p looks like binary data rather than text:
+// whether it contains a NUL byte, like git's heuristic, or many other control characters.
+// NULs are expected if they separate records.
+// Replacement characters, left by decoding bytes that aren't text, count as control characters.
+func looksBinary(p, sep []byte) bool {
+	nulSep := bytes.IndexByte(sep, 0) >= 0
+	ctl := bytes.Count(p, []byte("\uFFFD"))
+	for _, c := range p {
+		switch {
+		case c == 0 && !nulSep:
+			return true
+		case c < ' ' && !strings.ContainsRune("\x00\t\n\v\f\r\b\x1b", rune(c)):
+			ctl++
+		case c == 0x7f:
+			ctl++
+		}
+	}
+	return ctl*10 > len(p)
+}
+
+// hexRow formats row i of m's stream like hexdump -C.
+// It shows the stream's bytes as read, before any decoding.
+func (m Model) hexRow(i int) []span {
+	p := make([]byte, hexRowSize)
+	off := i * hexRowSize
+	n, _ := m.shared.Buffer().ReadAt(p, int64(off))
+	p = p[:n]
+	var b strings.Builder
+	for j := 0; j < hexRowSize; j++ {
+		if j == hexRowSize/2 {
+			b.WriteByte(' ')
+		}
+		if j < len(p) {
+			fmt.Fprintf(&b, "%02x ", p[j])
+		} else {
+			b.WriteString("   ")
+		}
+	}
+	b.WriteString(" |")
+	for _, c := range p {
+		if c < ' ' || c > '~' {
+			c = '.'
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte('|')
+	return []span{
+		{text: fmt.Sprintf("%08x", off), style: sgr{faint: true}},
+		{text: "  " + b.String()},
+	}
+}
@@ -0,0 +1,83 @@
+package streamview
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/josharian/pex/stream"
+)
+
+func TestLooksBinary(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		sep  string
+		want bool
+	}{
+		{"", "\n", false},
+		{"plain text\n", "\n", false},
+		{"a\x00b", "\n", true},
+		{"a\x00b\x00", "\x00", false}, // NULs separate records
+		{"\x1b[31mred\x1b[0m\ttab\r\n", "\n", false},
+		{"\x01" + strings.Repeat("a", 9), "\n", false}, // 1 in 10 is not too many
+		{"\x01" + strings.Repeat("a", 8), "\n", true},  // 1 in 9 is
+		{"\x7f\x7f" + strings.Repeat("a", 18), "\n", false},
+		{"\x7f\x7f" + strings.Repeat("a", 17), "\n", true},
+		{"�" + strings.Repeat("a", 7), "\n", false}, // 1 in 10 bytes
+		{"�" + strings.Repeat("a", 6), "\n", true},  // 1 in 9 bytes
+	} {
+		if got := looksBinary([]byte(tt.in), []byte(tt.sep)); got != tt.want {
+			t.Errorf("looksBinary(%q, %q) = %v, want %v", tt.in, tt.sep, got, tt.want)
+		}
+	}
+}
+
+func TestHexRow(t *testing.T) {
+	m := newTestModel("hello, world\x00\x01\xff~ and more", 80, 5)
+	for _, tt := range []struct {
+		i    int
+		want string
+	}{
+		{0, "00000000  68 65 6c 6c 6f 2c 20 77  6f 72 6c 64 00 01 ff 7e  |hello, world...~|"},
+		{1, "00000010  20 61 6e 64 20 6d 6f 72  65                       | and more|"},
+		{2, "00000020                                                    ||"},
+	} {
+		if got := strings.Join(texts(m.hexRow(tt.i)), ""); got != tt.want {
+			t.Errorf("hexRow(%d) = %q, want %q", tt.i, got, tt.want)
+		}
+	}
+}
+
+func TestHexOffsets(t *testing.T) {
+	m := newTestModel("abc\ndef\n", 80, 5)
+	for _, off := range []int{0, 3, 8} {
+		if got := m.rawOffset(off); got != off {
+			t.Errorf("rawOffset(%d) = %d, want %[1]d", off, got)
+		}
+		if got := m.textOffset(off); got != off {
+			t.Errorf("textOffset(%d) = %d, want %[1]d", off, got)
+		}
+	}
+
+	// UTF-16 takes two bytes for each character of ASCII text.
+	utf16 := "\xff\xfe" + strings.Repeat("a\x00", 4) + "\n\x00" + strings.Repeat("b\x00", 4) + "\n\x00"
+	sh := stream.NewShared(strings.NewReader(utf16), stream.Options{})
+	io.ReadAll(sh.Reader())
+	m = New(sh)
+	raw, text := sh.Buffer().Len(), m.buffer().Len()
+	if text != 10 {
+		t.Fatalf("decoded %d bytes of text, want 10", text)
+	}
+	for _, tt := range []struct{ text, raw int }{
+		{0, 0},
+		{5, raw / 2},
+		{text, raw},
+	} {
+		if got := m.rawOffset(tt.text); got != tt.raw {
+			t.Errorf("rawOffset(%d) = %d, want %d", tt.text, got, tt.raw)
+		}
+		if got := m.textOffset(tt.raw); got != tt.text {
+			t.Errorf("textOffset(%d) = %d, want %d", tt.raw, got, tt.text)
+		}
+	}
+}
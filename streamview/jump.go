@@ -0,0 +1,112 @@
+package streamview
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// jump is a pending go-to, waiting for the stream to reach its target.
+type jump struct {
+	kind    jumpKind
+	line    int     // for jumpLine
+	percent float64 // for jumpPercent
+}
+
+type jumpKind int
+
+const (
+	jumpNone    jumpKind = iota
+	jumpLine             // to a line, once it has been read
+	jumpPercent          // part way through the stream, once it has all been read
+	jumpEnd              // to the end, following the stream until it has all been read
+)
+
+// GotoLine scrolls to show line n at the top of the viewport,
+// reading ahead until the stream reaches it.
+func (m *Model) GotoLine(n int) tea.Cmd {
+	return m.startJump(jump{kind: jumpLine, line: n})
+}
+
+// GotoPercent scrolls to the line percent of the way through the stream,
+// reading ahead to the end of the stream to find it.
+func (m *Model) GotoPercent(percent float64) tea.Cmd {
+	return m.startJump(jump{kind: jumpPercent, percent: percent})
+}
+
+// GotoBottom scrolls to the end of the stream,
+// reading ahead and staying at the end until the stream ends.
+func (m *Model) GotoBottom() tea.Cmd {
+	return m.startJump(jump{kind: jumpEnd})
+}
+
+// ScrollToLine is like GotoLine, but leaves following the stream as it is,
+// for keeping views in step with one another.
+func (m *Model) ScrollToLine(n int) tea.Cmd {
+	if m.hex() {
+		// Go to the row holding the start of line n, if it has been read.
+		if off, ok := m.buffer().LineOffset(n); ok {
+			m.SetCurrentLine(m.rawOffset(off) / hexRowSize)
+		}
+		if m.shouldReadMore() {
+			return readCmd(m)
+		}
+		return nil
+	}
+	follow := m.Follow
+	cmd := m.GotoLine(n)
+	m.Follow = follow
+	return cmd
+}
+
+func (m *Model) startJump(j jump) tea.Cmd {
+	m.Follow = false
+	m.jump = j
+	m.updateJump()
+	if m.shouldReadMore() {
+		return readCmd(m)
+	}
+	return nil
+}
+
+// cancelJump abandons any pending go-to, for when the user scrolls elsewhere.
+func (m *Model) cancelJump() {
+	m.jump = jump{}
+}
+
+// updateJump completes the pending go-to, if the stream has reached its target.
+func (m *Model) updateJump() {
+	ended := m.shared.Err() != nil
+	switch m.jump.kind {
+	case jumpLine:
+		line := m.viewLine(m.jump.line)
+		if m.nlines() <= line && !ended {
+			return
+		}
+		m.SetCurrentLine(min(line, m.nlines()-1))
+	case jumpPercent:
+		if !ended {
+			return
+		}
+		m.SetCurrentLine(min(int(float64(m.nlines())*m.jump.percent/100), m.nlines()-1))
+	case jumpEnd:
+		m.showEnd()
+		if !ended {
+			return
+		}
+	}
+	m.jump = jump{}
+}
+
+// jumpText describes the pending go-to for the footer, such as "going to line 1,234".
+func (m Model) jumpText() string {
+	switch m.jump.kind {
+	case jumpLine:
+		return "going to line " + formatCount(m.jump.line+1)
+	case jumpPercent:
+		return fmt.Sprintf("going to %g%%", m.jump.percent)
+	case jumpEnd:
+		return "going to end"
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package streamview
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/josharian/pex/stream"
+)
+
+// numberedText returns n lines of text, numbered from first.
+func numberedText(first, n int) string {
+	var b strings.Builder
+	for i := first; i < first+n; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return b.String()
+}
+
+func TestJumpEnded(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		jump func(m *Model) tea.Cmd
+		want int
+	}{
+		{"line 5", func(m *Model) tea.Cmd { return m.GotoLine(5) }, 5},
+		{"line 100", func(m *Model) tea.Cmd { return m.GotoLine(100) }, 19}, // past the end
+		{"50%", func(m *Model) tea.Cmd { return m.GotoPercent(50) }, 10},
+		{"100%", func(m *Model) tea.Cmd { return m.GotoPercent(100) }, 19},
+		{"end", func(m *Model) tea.Cmd { return m.GotoBottom() }, 15},
+	} {
+		m := newTestModel(numberedText(0, 20), 20, 5)
+		tt.jump(&m)
+		if m.CurrentLine != tt.want || m.jump.kind != jumpNone {
+			t.Errorf("going to %s: got line %d, pending %v, want line %d, none pending", tt.name, m.CurrentLine, m.jump.kind, tt.want)
+		}
+	}
+}
+
+func TestJumpGrowing(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		jump    func(m *Model) tea.Cmd
+		growing int  // current line once the stream has 20 lines, but not ended
+		pending bool // whether the jump is still pending then
+		ended   int  // current line once it has 40 lines and ended
+	}{
+		{"line 15", func(m *Model) tea.Cmd { return m.GotoLine(15) }, 15, false, 15},
+		{"line 30", func(m *Model) tea.Cmd { return m.GotoLine(30) }, 0, true, 30},
+		{"50%", func(m *Model) tea.Cmd { return m.GotoPercent(50) }, 0, true, 20},
+		{"end", func(m *Model) tea.Cmd { return m.GotoBottom() }, 15, true, 35}, // following the stream
+	} {
+		pr, pw := io.Pipe()
+		sh := stream.NewShared(pr, stream.Options{})
+		r := sh.Reader()
+		feed := func(s string) {
+			go pw.Write([]byte(s))
+			io.ReadFull(r, make([]byte, len(s)))
+		}
+		m := New(sh)
+		m.Width, m.Height = 20, 5
+
+		feed(numberedText(0, 20))
+		tt.jump(&m)
+		if pending := m.jump.kind != jumpNone; m.CurrentLine != tt.growing || pending != tt.pending {
+			t.Errorf("going to %s in 20 lines: got line %d, pending %v, want line %d, pending %v", tt.name, m.CurrentLine, pending, tt.growing, tt.pending)
+		}
+		feed(numberedText(20, 20))
+		pw.Close()
+		io.ReadAll(r)
+		m.updateJump()
+		if m.CurrentLine != tt.ended || m.jump.kind != jumpNone {
+			t.Errorf("going to %s in 40 lines: got line %d, pending %v, want line %d, none pending", tt.name, m.CurrentLine, m.jump.kind, tt.ended)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package streamview
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/uniseg"
+)
+
+// renderRows renders line i of the view for display,
+// as rows at most width cells wide.
+// Unless wrapping, there is just one row, scrolled horizontally by m.XOffset.
+func (m Model) renderRows(i, width int) []string {
+	spans := m.spans(i)
+	if !m.wrapping() {
+		return []string{renderSpans(window(spans, m.XOffset, width))}
+	}
+	var rows []string
+	for _, row := range wrap(spans, width) {
+		rows = append(rows, renderSpans(row))
+	}
+	return rows
+}
+
+// spans parses line i of the view into spans ready to display,
+// with changes marked, when showing a diff, and search matches highlighted.
+func (m Model) spans(i int) []span {
+	parsed := parseANSI(m.line(i))
+	if m.ANSI == ANSIStrip {
+		for j := range parsed {
+			parsed[j].style = sgr{}
+		}
+	}
+	if m.diffing() {
+		parsed = m.diffSpans(parsed, i)
+	}
+	if !m.diffing() || m.diffLine(i).op != diffRemoved {
+		n := m.bufferLine(i)
+		parsed = highlight(parsed, m.lineMatches(n), m.currentMatch(n))
+	}
+	var spans []span
+	for _, sp := range parsed {
+		spans = appendEscaped(spans, sp)
+	}
+	return expand(spans, m.tabWidth(), m.Invisibles)
+}
+
+// renderSpans renders spans as text styled with ANSI escape sequences.
+func renderSpans(spans []span) string {
+	var b strings.Builder
+	for _, sp := range spans {
+		if sp.style == (sgr{}) {
+			b.WriteString(sp.text)
+			continue
+		}
+		b.WriteString(sp.style.lipgloss().Render(sp.text))
+	}
+	return b.String()
+}
+
+// appendEscaped appends sp to spans, with each run of bytes that are not valid UTF-8
+// replaced by visible escapes such as \xe9, in reverse video,
+// rather than by indistinguishable replacement characters.
+func appendEscaped(spans []span, sp span) []span {
+	s := sp.text
+	if utf8.ValidString(s) {
+		return append(spans, sp)
+	}
+	invalid := sp.style
+	invalid.reverse = !invalid.reverse
+	for len(s) > 0 {
+		n := invalidPrefix(s)
+		if n == 0 {
+			// Find the next invalid byte.
+			for n < len(s) {
+				r, size := utf8.DecodeRuneInString(s[n:])
+				if r == utf8.RuneError && size == 1 {
+					break
+				}
+				n += size
+			}
+			spans = append(spans, span{text: s[:n], style: sp.style})
+			s = s[n:]
+			continue
+		}
+		var esc strings.Builder
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&esc, `\x%02x`, s[i])
+		}
+		spans = append(spans, span{text: esc.String(), style: invalid})
+		s = s[n:]
+	}
+	return spans
+}
+
+// invalidPrefix returns the number of invalid UTF-8 bytes at the start of s.
+func invalidPrefix(s string) int {
+	n := 0
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if r != utf8.RuneError || size != 1 {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// expand expands tabs to spaces, with tab stops every tab cells,
+// and replaces other control characters with visible glyphs,
+// so that they can't disturb the layout.
+// If invisibles is set, it also makes tabs and trailing spaces visible.
+func expand(spans []span, tab int, invisibles bool) []span {
+	// Trailing whitespace, including any \r, starts at spans[ti].text[tj].
+	ti, tj := len(spans), 0
+	if invisibles {
+		for i := len(spans) - 1; i >= 0; i-- {
+			t := strings.TrimRight(spans[i].text, " \t\r")
+			ti, tj = i, len(t)
+			if t != "" {
+				break
+			}
+		}
+	}
+	var out []span
+	col := 0
+	for i, sp := range spans {
+		glyph := sp.style
+		glyph.faint = true
+		start := 0
+		flush := func(end int) {
+			if end > start {
+				out = append(out, span{text: sp.text[start:end], style: sp.style})
+				col += runewidth.StringWidth(sp.text[start:end])
+			}
+			start = end
+		}
+		for j, r := range sp.text {
+			trailing := i > ti || i == ti && j >= tj
+			var g string
+			style := glyph
+			switch {
+			case r == '\t':
+				flush(j) // to find the column
+				n := tab - col%tab
+				if invisibles {
+					g = "→" + strings.Repeat(" ", n-1)
+				} else {
+					g, style = strings.Repeat(" ", n), sp.style
+				}
+			case r == ' ' && invisibles && trailing:
+				g = "·"
+			case r < ' ':
+				g = string(rune(0x2400 + r)) // Control Pictures, such as ␀ and ␍
+			case r == 0x7f:
+				g = "␡"
+			case 0x80 <= r && r < 0xa0:
+				g = fmt.Sprintf(`\u%04x`, r) // C1 controls
+			default:
+				continue
+			}
+			flush(j)
+			out = append(out, span{text: g, style: style})
+			col += runewidth.StringWidth(g)
+			start = j + utf8.RuneLen(r)
+		}
+		flush(len(sp.text))
+	}
+	return out
+}
+
+// clip truncates spans to at most width cells.
+// A wide character cut in two by the right edge is replaced by spaces,
+// so that whatever follows stays in place.
+func clip(spans []span, width int) []span {
+	w := 0
+	for i, sp := range spans {
+		sw := runewidth.StringWidth(sp.text)
+		if w+sw > width {
+			spans[i].text = runewidth.FillRight(runewidth.Truncate(sp.text, width-w, ""), width-w)
+			return spans[:i+1]
+		}
+		w += sw
+	}
+	return spans
+}
+
+// wrap splits spans into rows at most width cells wide.
+// There is always at least one row.
+func wrap(spans []span, width int) [][]span {
+	var rows [][]span
+	var row []span
+	w := 0
+	for _, sp := range spans {
+		text := sp.text
+		for text != "" {
+			n, tw := fit(text, width-w)
+			if n == 0 && w == 0 {
+				// A character wider than the whole row. Give it a row anyway.
+				_, n = utf8.DecodeRuneInString(text)
+				tw = runewidth.StringWidth(text[:n])
+			}
+			if n > 0 {
+				row = append(row, span{text: text[:n], style: sp.style})
+				w += tw
+				text = text[n:]
+			}
+			if text != "" {
+				rows = append(rows, row)
+				row, w = nil, 0
+			}
+		}
+	}
+	return append(rows, row)
+}
+
+// fit returns the length in bytes of the longest prefix of s
+// that is at most width cells wide, and its width.
+// It does not split grapheme clusters.
+func fit(s string, width int) (n, w int) {
+	g := uniseg.NewGraphemes(s)
+	for g.Next() {
+		gw := runewidth.StringWidth(g.Str())
+		if w+gw > width {
+			n, _ = g.Positions()
+			return n, w
+		}
+		w += gw
+	}
+	return len(s), w
+}
+
+var (
+	scrollMarkerStyle = sgr{faint: true}
+	leftMarker        = span{text: "«", style: scrollMarkerStyle}
+	rightMarker       = span{text: "»", style: scrollMarkerStyle}
+)
+
+// window returns the part of spans at most width cells wide starting off cells in,
+// with markers at the edges where there is more content out of view.
+func window(spans []span, off, width int) []span {
+	if width <= 0 {
+		return nil
+	}
+	out, left, right := cut(spans, off, width)
+	if left {
+		out, _, _ = cut(out, 1, width-1)
+		out = append([]span{leftMarker}, out...)
+	}
+	if right {
+		out = append(clip(out, width-1), rightMarker)
+	}
+	return out
+}
+
+// cut returns the part of spans at most width cells wide starting off cells in,
+// and whether any content was cut off to the left and to the right of it.
+// A wide character cut in two by the left edge is replaced by spaces.
+func cut(spans []span, off, width int) (out []span, left, right bool) {
+	col := 0
+	for _, sp := range spans {
+		// Within sp, the kept graphemes are sp.text[start:end].
+		start, end := -1, -1
+		g := uniseg.NewGraphemes(sp.text)
+		for g.Next() {
+			from, to := g.Positions()
+			w := runewidth.StringWidth(g.Str())
+			switch {
+			case col < off:
+				left = true
+				if col+w > off {
+					out = append(out, span{text: strings.Repeat(" ", col+w-off), style: sp.style})
+				}
+			case col+w > off+width:
+				right = true
+			default:
+				if start < 0 {
+					start = from
+				}
+				end = to
+			}
+			if right {
+				break
+			}
+			col += w
+		}
+		if start >= 0 {
+			out = append(out, span{text: sp.text[start:end], style: sp.style})
+		}
+		if right {
+			break
+		}
+	}
+	return out, left, right
+}
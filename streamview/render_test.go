@@ -0,0 +1,176 @@
+package streamview
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// texts returns the text of each of spans.
+func texts(spans []span) []string {
+	var out []string
+	for _, sp := range spans {
+		out = append(out, sp.text)
+	}
+	return out
+}
+
+func TestExpand(t *testing.T) {
+	for _, tt := range []struct {
+		in         string
+		tab        int
+		invisibles bool
+		want       string
+	}{
+		// Tab stops.
+		{"a\tb", 4, false, "a   b"},
+		{"abc\tb", 4, false, "abc b"},
+		{"abcd\tb", 4, false, "abcd    b"},
+		{"a\tb\tc", 8, false, "a       b       c"},
+		{"日\tb", 4, false, "日  b"},
+		{"é\tb", 4, false, "é   b"},
+		{"\t\t", 2, false, "    "},
+		{"a\tb", 1, false, "a b"},
+		// Control characters.
+		{"a\x00b\rc\x7f", 4, false, "a␀b␍c␡"},
+		{"\x1b\x01", 4, false, "␛␁"},
+		{"a\u0085b", 4, false, `a\u0085b`},
+		{"a b ", 4, false, "a b "},
+		// Invisibles.
+		{"a\tb  ", 4, true, "a→  b··"},
+		{"a\t\tb", 4, true, "a→  →   b"},
+		{"a b \r", 4, true, "a b·␍"},
+		{"a \t", 4, true, "a·→ "},
+		{"   ", 4, true, "···"},
+		{"", 4, true, ""},
+	} {
+		got := strings.Join(texts(expand([]span{{text: tt.in}}, tt.tab, tt.invisibles)), "")
+		if got != tt.want {
+			t.Errorf("expand(%q, %d, %v) = %q, want %q", tt.in, tt.tab, tt.invisibles, got, tt.want)
+		}
+	}
+	// Tab stops count from the start of the line, across spans.
+	spans := expand([]span{{text: "ab"}, {text: "\tc", style: sgr{bold: true}}}, 4, false)
+	want := []span{{text: "ab"}, {text: "  ", style: sgr{bold: true}}, {text: "c", style: sgr{bold: true}}}
+	if !slices.Equal(spans, want) {
+		t.Errorf("expand across spans = %+v, want %+v", spans, want)
+	}
+}
+
+func TestExpandGlyphs(t *testing.T) {
+	// Glyphs are faint versions of the text around them.
+	// Only spaces at the end of the line are trailing, even across spans.
+	red, faintRed := sgr{fg: "1"}, sgr{fg: "1", faint: true}
+	spans := expand([]span{{text: "a b", style: red}, {text: " \t"}, {text: " \x00"}}, 4, true)
+	want := []span{
+		{text: "a b", style: red},
+		{text: " "}, {text: "→   ", style: sgr{faint: true}},
+		{text: " "}, {text: "␀", style: sgr{faint: true}},
+	}
+	if !slices.Equal(spans, want) {
+		t.Errorf("expand with invisibles = %+v, want %+v", spans, want)
+	}
+	spans = expand([]span{{text: "a"}, {text: " \t ", style: red}}, 4, true)
+	want = []span{{text: "a"}, {text: "·", style: faintRed}, {text: "→ ", style: faintRed}, {text: "·", style: faintRed}}
+	if !slices.Equal(spans, want) {
+		t.Errorf("expand with trailing invisibles = %+v, want %+v", spans, want)
+	}
+}
+
+func TestFit(t *testing.T) {
+	for _, tt := range []struct {
+		s     string
+		width int
+		n, w  int
+	}{
+		{"abc", 5, 3, 3},
+		{"abc", 2, 2, 2},
+		{"abc", 0, 0, 0},
+		{"日本語", 5, 6, 4},
+		{"日本語", 1, 0, 0},
+		{"e\u0301e\u0301", 1, 3, 1},        // a combining accent stays with its letter
+		{"\U0001F44D\U0001F3FDx", 2, 8, 2}, // as does a skin tone modifier
+	} {
+		n, w := fit(tt.s, tt.width)
+		if n != tt.n || w != tt.w {
+			t.Errorf("fit(%q, %d) = %d, %d, want %d, %d", tt.s, tt.width, n, w, tt.n, tt.w)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	for _, tt := range []struct {
+		in    []string // span texts
+		width int
+		want  []string // row texts
+	}{
+		{[]string{""}, 3, []string{""}},
+		{[]string{"abcdefg"}, 3, []string{"abc", "def", "g"}},
+		{[]string{"abc"}, 3, []string{"abc"}},
+		{[]string{"ab", "cd", "e"}, 3, []string{"abc", "de"}},
+		{[]string{"a日本"}, 3, []string{"a日", "本"}},
+		{[]string{"日本"}, 1, []string{"日", "本"}}, // too wide for any row
+	} {
+		var spans []span
+		for _, s := range tt.in {
+			spans = append(spans, span{text: s})
+		}
+		var got []string
+		for _, row := range wrap(spans, tt.width) {
+			got = append(got, strings.Join(texts(row), ""))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("wrap(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestCut(t *testing.T) {
+	for _, tt := range []struct {
+		in          string
+		off, width  int
+		want        string
+		left, right bool
+	}{
+		{"abcdef", 0, 6, "abcdef", false, false},
+		{"abcdef", 2, 2, "cd", true, true},
+		{"abcdef", 4, 5, "ef", true, false},
+		{"abc", 5, 3, "", true, false},
+		{"日本語", 1, 4, " 本", true, true}, // the cut-off half of 日 is a space
+		{"日本語", 2, 3, "本", true, true},  // no room for half of 語
+		{"éxyz", 0, 2, "éx", false, true},
+		{"éxyz", 1, 2, "xy", true, true},
+	} {
+		out, left, right := cut([]span{{text: tt.in}}, tt.off, tt.width)
+		if got := strings.Join(texts(out), ""); got != tt.want || left != tt.left || right != tt.right {
+			t.Errorf("cut(%q, %d, %d) = %q, %v, %v, want %q, %v, %v", tt.in, tt.off, tt.width, got, left, right, tt.want, tt.left, tt.right)
+		}
+	}
+	// Styles are kept.
+	bold := sgr{bold: true}
+	out, _, _ := cut([]span{{text: "ab"}, {text: "cd", style: bold}}, 1, 2)
+	if want := []span{{text: "b"}, {text: "c", style: bold}}; !slices.Equal(out, want) {
+		t.Errorf("cut across spans = %+v, want %+v", out, want)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	for _, tt := range []struct {
+		in         string
+		off, width int
+		want       string
+	}{
+		{"abcdef", 0, 6, "abcdef"},
+		{"abcdef", 0, 4, "abc»"},
+		{"abcdef", 2, 4, "«def"},
+		{"abcdef", 2, 3, "«d»"},
+		{"abc", 0, 0, ""},
+		{"日本語", 0, 4, "日 »"}, // the cut-off half of 本 is a space
+		{"日本語", 1, 5, "«本語"},
+		{"日本語x", 2, 4, "«  »"}, // as are the halves of 本 and 語 next to the markers
+	} {
+		if got := strings.Join(texts(window([]span{{text: tt.in}}, tt.off, tt.width)), ""); got != tt.want {
+			t.Errorf("window(%q, %d, %d) = %q, want %q", tt.in, tt.off, tt.width, got, tt.want)
+		}
+	}
+}
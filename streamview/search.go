@@ -0,0 +1,305 @@
+package streamview
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
+)
+
+// search is the state of a search through the stream.
+// Lines are searched in the background, in order,
+// so matches are found even in lines that have not been shown yet.
+type search struct {
+	query   string
+	re      *regexp.Regexp // nil if not searching
+	id      uint64         // distinguishes this search's results from stale ones
+	matches []match        // sorted
+	cur     int            // index of the current match, or -1
+	origin  int            // line the search started from
+	next    int            // first line that may have unsearched text
+	size    int            // buffer length when searching up to next
+	more    bool           // there are complete lines past next to search
+	running bool           // a searchCmd is in flight
+}
+
+// match is an occurrence of the search query.
+type match struct {
+	line       int
+	start, end int // byte offsets in the line, with escape sequences removed
+}
+
+var searchID atomic.Uint64
+
+// searchChunk is the most lines that one searchCmd searches,
+// so that the match count for long streams keeps up as it grows.
+const searchChunk = 10000
+
+type searchMsg struct {
+	id      uint64
+	from    int
+	next    int
+	size    int
+	more    bool
+	matches []match
+}
+
+// searchCmd searches the lines from m.search.next on.
+func searchCmd(m *Model) tea.Cmd {
+	m.search.running = true
+	buf, re, id := m.buffer(), m.search.re, m.search.id
+	from := max(m.search.next, buf.FirstLine())
+	return func() tea.Msg {
+		// Record the length first, so that anything appended during the search
+		// is searched again later.
+		size := buf.Len()
+		n := buf.NLines()
+		to := min(n, from+searchChunk)
+		var matches []match
+		for i := from; i < to; i++ {
+			for _, loc := range re.FindAllStringIndex(plainText(buf.Line(i)), -1) {
+				matches = append(matches, match{line: i, start: loc[0], end: loc[1]})
+			}
+		}
+		next := to
+		if to == n {
+			// The last line may not be complete yet.
+			next = max(from, to-1)
+		}
+		return searchMsg{id: id, from: from, next: next, size: size, more: to < n, matches: matches}
+	}
+}
+
+// Search starts searching the stream for query, replacing any previous search,
+// and shows the first match at or after the top of the view once it is found.
+// Lowercase queries match case-insensitively.
+// An empty query ends the search.
+func (m *Model) Search(query string) tea.Cmd {
+	m.search = search{query: query, id: searchID.Add(1), cur: -1, origin: m.CurrentLine}
+	if query == "" {
+		return nil
+	}
+	expr := regexp.QuoteMeta(query)
+	if !strings.ContainsFunc(query, unicode.IsUpper) {
+		expr = "(?i)" + expr
+	}
+	m.search.re = regexp.MustCompile(expr)
+	return searchCmd(m)
+}
+
+// Query returns the current search query, or "" if there is none.
+func (m Model) Query() string {
+	return m.search.query
+}
+
+// searchMore continues the search if there is more to search and no search running.
+func (m *Model) searchMore() tea.Cmd {
+	s := &m.search
+	if s.re == nil || s.running {
+		return nil
+	}
+	if !s.more && m.buffer().Len() == s.size {
+		return nil
+	}
+	return searchCmd(m)
+}
+
+// updateSearch incorporates the results of a searchCmd.
+func (m *Model) updateSearch(msg searchMsg) tea.Cmd {
+	s := &m.search
+	if msg.id != s.id {
+		return nil
+	}
+	s.running = false
+	// Lines from msg.from on were searched again.
+	i := sort.Search(len(s.matches), func(i int) bool { return s.matches[i].line >= msg.from })
+	s.matches = append(s.matches[:i], msg.matches...)
+	s.cur = min(s.cur, len(s.matches)-1)
+	s.next, s.size, s.more = msg.next, msg.size, msg.more
+	if s.cur < 0 {
+		// Show the first match as soon as there is one.
+		if j := m.matchAt(s.origin); j < len(s.matches) {
+			m.showMatch(j)
+		}
+	}
+	return m.searchMore()
+}
+
+// matchAt returns the index of the first remaining match at or after line.
+func (m Model) matchAt(line int) int {
+	line = max(line, m.buffer().FirstLine())
+	return sort.Search(len(m.search.matches), func(i int) bool { return m.search.matches[i].line >= line })
+}
+
+// SearchNext shows the next match.
+func (m *Model) SearchNext() {
+	j := m.search.cur + 1
+	if m.search.cur < 0 {
+		j = m.matchAt(m.bufferLine(m.CurrentLine))
+	}
+	// Skip matches in lines that have since been discarded.
+	j = max(j, m.matchAt(0))
+	if j < len(m.search.matches) {
+		m.showMatch(j)
+	}
+}
+
+// SearchPrev shows the previous match.
+func (m *Model) SearchPrev() {
+	j := m.search.cur - 1
+	if m.search.cur < 0 {
+		j = m.matchAt(m.bufferLine(m.CurrentLine)) - 1
+	}
+	if j >= m.matchAt(0) {
+		m.showMatch(j)
+	}
+}
+
+// showMatch makes match j the current match and scrolls it into view.
+func (m *Model) showMatch(j int) {
+	m.search.cur = j
+	mt := m.search.matches[j]
+	if m.hex() {
+		return
+	}
+	m.Follow = false
+	line := m.viewLine(mt.line)
+	if line >= m.nlines() {
+		// The diff hasn't reached the match yet.
+		return
+	}
+	if !m.visible(line) {
+		m.SetCurrentLine(line)
+	}
+	if m.wrapping() {
+		return
+	}
+	// Scroll sideways, if needed, to show the start of the match.
+	width := m.wrapWidth()
+	text := plainText(m.line(line))
+	if mt.start > len(text) {
+		// The line has been discarded.
+		return
+	}
+	prefix := text[:mt.start]
+	col := 0
+	for _, sp := range expand([]span{{text: prefix}}, m.tabWidth(), m.Invisibles) {
+		col += runewidth.StringWidth(sp.text)
+	}
+	if col < m.XOffset || col >= m.XOffset+width-1 {
+		m.XOffset = max(0, col-width/3)
+	}
+}
+
+// visible reports whether line is at least partly visible.
+func (m Model) visible(line int) bool {
+	nums := m.visibleNums()
+	if len(nums) == 0 || line < nums[0] {
+		return false
+	}
+	if m.wrapping() {
+		// Count rows, to find whether line is pushed off the bottom.
+		width := m.wrapWidth()
+		rows := -m.CurrentSubline
+		for _, i := range nums {
+			if i == line {
+				return rows < m.Height
+			}
+			rows += m.rows(i, width)
+		}
+		return false
+	}
+	return line <= nums[len(nums)-1]
+}
+
+// currentMatch returns the current match, if it is in line n of the stream.
+func (m Model) currentMatch(n int) *match {
+	if c := m.search.cur; c >= 0 && m.search.matches[c].line == n {
+		return &m.search.matches[c]
+	}
+	return nil
+}
+
+// lineMatches returns the matches in line i.
+func (m Model) lineMatches(i int) []match {
+	ms := m.search.matches
+	j := sort.Search(len(ms), func(j int) bool { return ms[j].line >= i })
+	k := j
+	for k < len(ms) && ms[k].line == i {
+		k++
+	}
+	return ms[j:k]
+}
+
+// searchText returns a summary of the search for the footer, such as "3/12 matches".
+func (m Model) searchText() string {
+	if m.search.re == nil {
+		return ""
+	}
+	n := len(m.search.matches) - m.matchAt(0)
+	more := ""
+	if m.search.running || m.search.more {
+		more = "+"
+	}
+	if c := m.search.cur - m.matchAt(0); c >= 0 {
+		return fmt.Sprintf("%d/%d%s matches", c+1, n, more)
+	}
+	return fmt.Sprintf("%d%s matches", n, more)
+}
+
+var (
+	matchStyle        = sgr{fg: "0", bg: "3"}               // black on yellow
+	currentMatchStyle = sgr{fg: "0", bg: "208", bold: true} // black on orange
+)
+
+// highlight restyles the parts of spans covered by matches.
+// Match offsets are into the concatenated text of spans.
+func highlight(spans []span, matches []match, cur *match) []span {
+	if len(matches) == 0 {
+		return spans
+	}
+	var out []span
+	off := 0 // offset of the start of the current span
+	for _, sp := range spans {
+		end := off + len(sp.text)
+		pos := off
+		for _, mt := range matches {
+			lo, hi := max(mt.start, pos), min(mt.end, end)
+			if lo >= hi {
+				continue
+			}
+			if lo > pos {
+				out = append(out, span{text: sp.text[pos-off : lo-off], style: sp.style})
+			}
+			style := matchStyle
+			if cur != nil && mt == *cur {
+				style = currentMatchStyle
+			}
+			out = append(out, span{text: sp.text[lo-off : hi-off], style: style})
+			pos = hi
+		}
+		if pos < end {
+			out = append(out, span{text: sp.text[pos-off:], style: sp.style})
+		}
+		off = end
+	}
+	return out
+}
+
+// plainText returns line with any escape sequences removed,
+// which is the text that searches match.
+func plainText(line string) string {
+	if !strings.Contains(line, "\x1b") {
+		return line
+	}
+	var b strings.Builder
+	for _, sp := range parseANSI(line) {
+		b.WriteString(sp.text)
+	}
+	return b.String()
+}
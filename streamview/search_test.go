@@ -0,0 +1,137 @@
+package streamview
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/josharian/pex/stream"
+)
+
+func TestHighlight(t *testing.T) {
+	red := sgr{fg: "1"}
+	spans := []span{{text: "hello ", style: red}, {text: "world"}}
+	for _, tt := range []struct {
+		matches []match
+		cur     int // index of the current match, or -1
+		want    []span
+	}{
+		{nil, -1, spans},
+		{
+			[]match{{start: 1, end: 3}}, -1,
+			[]span{{text: "h", style: red}, {text: "el", style: matchStyle}, {text: "lo ", style: red}, {text: "world"}},
+		},
+		{
+			// A match across spans, and another in the same span.
+			[]match{{start: 4, end: 7}, {start: 8, end: 10}}, 1,
+			[]span{
+				{text: "hell", style: red}, {text: "o ", style: matchStyle}, {text: "w", style: matchStyle},
+				{text: "o"}, {text: "rl", style: currentMatchStyle}, {text: "d"},
+			},
+		},
+		{
+			[]match{{start: 0, end: 11}}, 0,
+			[]span{{text: "hello ", style: currentMatchStyle}, {text: "world", style: currentMatchStyle}},
+		},
+	} {
+		var cur *match
+		if tt.cur >= 0 {
+			cur = &tt.matches[tt.cur]
+		}
+		if got := highlight(slices.Clone(spans), tt.matches, cur); !slices.Equal(got, tt.want) {
+			t.Errorf("highlight(%v, current %d) = %+v, want %+v", tt.matches, tt.cur, got, tt.want)
+		}
+	}
+}
+
+// runSearch completes the search m has started with cmd,
+// as far as it can without more of the stream.
+func runSearch(m *Model, cmd tea.Cmd) {
+	for cmd != nil {
+		cmd = m.updateSearch(cmd().(searchMsg))
+	}
+}
+
+func TestSearchChunks(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 2*searchChunk+10; i++ {
+		fmt.Fprintf(&b, "Line %d\n", i)
+	}
+	m := newTestModel(b.String(), 20, 5)
+	cmd := m.Search("line 1") // matches case-insensitively
+	msg := cmd().(searchMsg)
+	if msg.next != searchChunk || !msg.more {
+		t.Errorf("first chunk: got next %d, more %v, want %d, true", msg.next, msg.more, searchChunk)
+	}
+	runSearch(&m, m.updateSearch(msg))
+	// Line 1, Line 10-19, Line 100-199, Line 1000-1999, Line 10000-19999
+	if got, want := len(m.search.matches), 1+10+100+1000+10000; got != want {
+		t.Errorf("got %d matches, want %d", got, want)
+	}
+	if m.search.cur != 0 || m.CurrentLine != 0 {
+		t.Errorf("got current match %d on line %d, want the first, on line 0", m.search.cur, m.CurrentLine)
+	}
+}
+
+func TestSearchGrowing(t *testing.T) {
+	pr, pw := io.Pipe()
+	sh := stream.NewShared(pr, stream.Options{})
+	r := sh.Reader()
+	feed := func(s string) {
+		go pw.Write([]byte(s))
+		io.ReadFull(r, make([]byte, len(s)))
+	}
+	m := New(sh)
+	m.Width, m.Height = 20, 5
+
+	feed("foo\nbar\nfo")
+	runSearch(&m, m.Search("foo"))
+	// The last line may be incomplete, so it is searched again later.
+	if m.search.next != 2 || len(m.search.matches) != 1 {
+		t.Errorf("got next %d, %d matches, want 2, 1", m.search.next, len(m.search.matches))
+	}
+	if m.searchMore() != nil {
+		t.Errorf("searching again without more of the stream")
+	}
+
+	feed("o\nfoo")
+	runSearch(&m, m.searchMore())
+	want := []match{{0, 0, 3}, {2, 0, 3}, {3, 0, 3}}
+	if !slices.Equal(m.search.matches, want) {
+		t.Errorf("got matches %v, want %v", m.search.matches, want)
+	}
+	pw.Close()
+}
+
+func TestSearchDiscarded(t *testing.T) {
+	pr, pw := io.Pipe()
+	sh := stream.NewShared(pr, stream.Options{TailLines: 10})
+	r := sh.Reader()
+	feed := func(s string) {
+		// Reading skips discarded data, so wait for the stream to grow instead.
+		want := sh.Buffer().Len() + len(s)
+		go pw.Write([]byte(s))
+		for sh.Buffer().Len() < want {
+			r.Read(make([]byte, len(s)))
+		}
+	}
+	m := New(sh)
+	m.Width, m.Height = 20, 5
+
+	feed("x foo\nx foo\nx foo\n")
+	runSearch(&m, m.Search("foo"))
+	feed(strings.Repeat("more\n", 50))
+	// The remaining matches are in discarded lines, so there is no next match.
+	m.SearchNext()
+	m.SearchNext()
+	feed("x foo\n")
+	runSearch(&m, m.searchMore())
+	m.SearchNext()
+	if c := m.search.cur; m.search.matches[c].line != 53 {
+		t.Errorf("got current match in line %d, want 53", m.search.matches[c].line)
+	}
+	pw.Close()
+}
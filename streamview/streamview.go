@@ -1,6 +1,7 @@
 package streamview
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
@@ -12,27 +13,72 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/josharian/pex/stream"
+	"github.com/mattn/go-runewidth"
 )
 
 // New returns a new model with default key mappings.
 // The zero value is not valid.
 func New(shared *stream.Shared) (m Model) {
-	// todo: re-enable, needs a hit-test so we only scroll the correct column
-	m.MouseWheelEnabled = false
+	m.MouseWheelEnabled = true
 	m.MouseWheelDelta = 3
-	m.buffer = shared.Buffer()
-	m.reader = shared.Reader()
+	m.search.cur = -1
+	m.shared = shared
+	ctx, cancel := context.WithCancel(context.Background())
+	m.reader = shared.ReaderContext(ctx)
+	m.ctx, m.cancel = ctx, cancel
 	m.id = streamviewID.Add(1)
 	return m
 }
 
 var streamviewID atomic.Uint64
 
+// Settings are per-column display settings.
+type Settings struct {
+	// TimeGutter selects the time shown beside each line.
+	// It requires a stream with timestamps; see stream.Options.
+	TimeGutter TimeMode
+
+	// Hex selects whether to show a hex dump instead of lines of text.
+	Hex HexMode
+
+	// ANSI selects whether to show or strip colors from ANSI escape sequences.
+	ANSI ANSIMode
+
+	// TabWidth is the distance between tab stops. Zero means 8.
+	TabWidth int
+
+	// Invisibles makes tabs, trailing spaces and carriage returns visible.
+	Invisibles bool
+
+	// Wrap wraps long lines instead of truncating them.
+	Wrap bool
+
+	// LineNumbers shows 1-based line numbers beside each line.
+	LineNumbers bool
+
+	// Follow keeps the end of the stream in view as it grows, like less +F.
+	// Scrolling up turns it off.
+	Follow bool
+}
+
+func (s Settings) tabWidth() int {
+	if s.TabWidth <= 0 {
+		return 8
+	}
+	return s.TabWidth
+}
+
 // Model is the Bubble Tea model for this viewport element.
 type Model struct {
 	Name string // for debugging
 	id   uint64
 
+	// Title is shown in the top border, if there is one.
+	// Status is shown after it, and is kept when Title is truncated to fit.
+	// Status may be styled.
+	Title  string
+	Status string
+
 	Width  int
 	Height int
 
@@ -47,7 +93,15 @@ type Model struct {
 	// It may be larger than the number of lines.
 	CurrentLine int
 
-	// TODO: subline count for line wrapping
+	// CurrentSubline is the row of CurrentLine at the top of the viewport,
+	// when wrapping long lines, 0-based.
+	CurrentSubline int
+
+	// XOffset is how far the viewport is scrolled to the right, in cells.
+	// It has no effect when wrapping long lines.
+	XOffset int
+
+	Settings
 
 	// Style applies a lipgloss style to the viewport. Realistically, it's most
 	// useful for setting borders, margins and padding.
@@ -56,12 +110,18 @@ type Model struct {
 
 	focused bool
 	lastErr error
+	reading bool // a readCmd is in flight
+	binary  bool // the stream looks binary
+	sniffed bool // binary is final
+	search  search
+	diff    diffState
+	jump    jump // a pending go-to
 	// lastSleep time.Time
-	// TODO:
-	// linewrap bool
 
-	buffer *stream.Buffer
+	shared *stream.Shared
 	reader *stream.Reader
+	ctx    context.Context // done when the model is closed
+	cancel func()
 }
 
 type readMsg struct {
@@ -82,68 +142,257 @@ func readCmd(m *Model) tea.Cmd {
 		"id", m.id,
 		"triggered by", fmt.Sprintf("%s:%d %s", frame.File, frame.Line, frame.Function),
 	)
+	m.reading = true
+	id, reader := m.id, m.reader
 	return func() tea.Msg {
-		slog.Debug("streamview.readCmd", "id", m.id, "lastErr", m.lastErr)
-		if m.lastErr == io.EOF {
-			// The error might just be because the program is still working.
-			// Avoid busy-looping, but keep trying.
-			time.Sleep(50 * time.Millisecond)
-		}
+		// This blocks until there is new data, either from our own read
+		// or from another reader of the same stream, or until the stream fails.
+		// Data that is already buffered doesn't count.
+		reader.Seek(0, io.SeekEnd)
 		buf := make([]byte, 4096)
-		_, err := m.reader.Read(buf)
-		return readMsg{id: m.id, err: err}
+		_, err := reader.Read(buf)
+		slog.Debug("streamview.readCmd done", "id", id, "err", err)
+		return readMsg{id: id, err: err}
+	}
+}
+
+// changedMsg reports that a streamview's stream has changed,
+// so that it gets re-rendered.
+type changedMsg struct {
+	id uint64 // of the streamview whose stream changed
+}
+
+// statsInterval is the minimum time between re-renders due to stream changes.
+const statsInterval = 100 * time.Millisecond
+
+// watchCmd waits for s to change, then sends msg.
+// A stream may be consumed by readers other than m,
+// and m's stats should stay current as they do.
+func watchCmd(ctx context.Context, s *stream.Shared, msg tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case <-s.Changed():
+		case <-ctx.Done():
+			return nil
+		}
+		// Coalesce bursts of changes.
+		select {
+		case <-time.After(statsInterval):
+		case <-ctx.Done():
+			return nil
+		}
+		return msg
 	}
 }
 
-func (m Model) Init() tea.Cmd {
-	return readCmd(&m)
+func (m *Model) Init() tea.Cmd {
+	return tea.Batch(readCmd(m), watchCmd(m.ctx, m.shared, changedMsg{id: m.id}))
+}
+
+// buffer returns the buffer of text to display.
+func (m Model) buffer() *stream.Buffer {
+	return m.shared.Text()
 }
 
 // AtTop returns whether or not the viewport is at the very top position.
 func (m Model) AtTop() bool {
-	return m.CurrentLine <= 0
+	return m.CurrentLine <= m.minLine()
 }
 
 // AtBottom returns whether or not the viewport is at or past the very bottom
 // position.
 func (m Model) AtBottom() bool {
 	_, bottom := m.visibleLineRange()
-	return m.CurrentLine >= bottom
+	if m.CurrentLine == bottom {
+		return m.CurrentSubline >= m.rows(bottom, m.wrapWidth())-1
+	}
+	return m.CurrentLine > bottom
 }
 
 // PastBottom returns whether or not the viewport is scrolled beyond the last
-// line. This can happen when adjusting the viewport height.
+// line, or when wrapping, beyond the last row of the last line.
+// This can happen when adjusting the viewport size.
 func (m Model) PastBottom() bool {
-	return m.CurrentLine > m.maxLine()
+	if !m.wrapping() {
+		return m.CurrentLine > m.maxLine()
+	}
+	last := max(0, m.nlines()-1)
+	if m.CurrentLine == last {
+		return m.CurrentSubline >= m.rows(last, m.wrapWidth())
+	}
+	return m.CurrentLine > last
+}
+
+// minLine returns the minimum possible value of the y-offset.
+// If lines have been discarded from the buffer,
+// a single line standing in for them is shown above the first remaining line.
+func (m Model) minLine() int {
+	return max(0, m.firstLine()-1)
 }
 
 // maxLine returns the maximum possible value of the y-offset based on the
 // viewport's content and set height.
 func (m Model) maxLine() int {
+	if m.diffing() {
+		// A diff has no lines past its end to show.
+		return max(0, m.nlines()-1)
+	}
 	// allow scrolling past the end of the file
 	// require one line to be visible at top
-	return m.buffer.NLines() + m.Height - 1
+	return m.nlines() + m.Height - 1
+}
+
+// nlines returns the total number of lines, or of rows in hex mode,
+// including discarded ones.
+func (m Model) nlines() int {
+	if m.diffing() {
+		return len(m.diff.lines)
+	}
+	if m.hex() {
+		return (m.shared.Buffer().Len() + hexRowSize - 1) / hexRowSize
+	}
+	return m.buffer().NLines()
+}
+
+// firstLine returns the first line, or row in hex mode, that has not been discarded.
+func (m Model) firstLine() int {
+	if m.diffing() {
+		return 0
+	}
+	if m.hex() {
+		// Skip any partially discarded row.
+		return (m.shared.Buffer().Start() + hexRowSize - 1) / hexRowSize
+	}
+	return m.buffer().FirstLine()
 }
 
 func (m Model) visibleLineRange() (top, bottom int) {
-	top = max(0, m.CurrentLine)
-	bottom = clamp(m.maxLine(), top, m.buffer.NLines()-1)
+	top = max(m.minLine(), m.CurrentLine)
+	if m.diffing() {
+		// Show the end of the diff, rather than a gap past it.
+		top = min(top, m.nlines()-1)
+	}
+	bottom = clamp(m.maxLine(), top, m.nlines()-1)
 	return top, bottom
 }
 
-// visibleLines returns the lines that should currently be visible in the
-// viewport.
-func (m Model) visibleLines() (lines []string) {
+// visibleNums returns the numbers of the lines that are at least partly visible.
+func (m Model) visibleNums() []int {
 	if !m.hasLines() {
 		return nil
 	}
 	top, bottom := m.visibleLineRange()
+	// Lines past the bottom of the screen would be truncated anyway.
+	bottom = min(bottom, top+m.Height-1)
+	nums := make([]int, 0, bottom-top+1)
 	for i := top; i <= bottom; i++ {
-		lines = append(lines, m.buffer.Line(i))
+		nums = append(nums, i)
+	}
+	return nums
+}
+
+// visibleLines returns the lines that should currently be visible in the
+// viewport, one per row.
+func (m Model) visibleLines() (lines []string) {
+	nums := m.visibleNums()
+	first := m.firstLine()
+	hex := m.hex()
+	width, height := m.contentSize()
+	var gutters []string
+	if !hex {
+		gutters = m.gutters(nums, first)
+	}
+	for j, i := range nums {
+		switch {
+		case i < first && hex:
+			lines = append(lines, discardedStyle.Render(fmt.Sprintf("[%d earlier bytes discarded]", first*hexRowSize)))
+			continue
+		case i < first:
+			lines = append(lines, discardedStyle.Render(fmt.Sprintf("[%d earlier lines discarded]", first)))
+			continue
+		case hex:
+			lines = append(lines, renderSpans(window(m.hexRow(i), m.XOffset, width)))
+			continue
+		}
+		gutter := ""
+		if gutters != nil {
+			gutter = gutters[j]
+		}
+		rows := m.renderRows(i, width-lipgloss.Width(gutter))
+		if j == 0 {
+			rows = rows[min(m.CurrentSubline, len(rows)-1):]
+		}
+		for k, row := range rows {
+			if k > 0 {
+				// Continuation rows get a blank gutter.
+				gutter = strings.Repeat(" ", lipgloss.Width(gutter))
+			}
+			lines = append(lines, gutter+row)
+		}
+		if len(lines) >= height {
+			break
+		}
 	}
 	return lines
 }
 
+// wrapping reports whether m is wrapping long lines.
+func (m Model) wrapping() bool {
+	return m.Wrap && !m.hex()
+}
+
+// wrapWidth returns the width at which lines are wrapped.
+func (m Model) wrapWidth() int {
+	width, _ := m.contentSize()
+	if g := m.gutters(m.visibleNums(), m.firstLine()); g != nil {
+		width -= lipgloss.Width(g[0])
+	}
+	return width
+}
+
+// rows returns the number of rows line i takes up, when wrapped at width.
+func (m Model) rows(i, width int) int {
+	if !m.wrapping() || i < m.firstLine() || i >= m.nlines() {
+		return 1
+	}
+	return len(wrap(m.spans(i), width))
+}
+
+// visibleRowCount returns the number of rows with content in the viewport.
+func (m Model) visibleRowCount() int {
+	if !m.wrapping() {
+		return m.VisibleLineCount()
+	}
+	width := m.wrapWidth()
+	n := -m.CurrentSubline
+	for _, i := range m.visibleNums() {
+		n += m.rows(i, width)
+		if n >= m.Height {
+			break
+		}
+	}
+	return n
+}
+
+// line returns the text of line i of the view.
+func (m Model) line(i int) string {
+	buf := m.buffer()
+	if m.diffing() {
+		l := m.diffLine(i)
+		i = l.b
+		if l.op == diffRemoved {
+			buf, i = m.diff.base.Text(), l.a
+		}
+	}
+	if m.Invisibles {
+		// Show any \r before the \n, too.
+		return buf.RawLine(i)
+	}
+	return buf.Line(i)
+}
+
+var discardedStyle = lipgloss.NewStyle().Faint(true)
+
 func (m Model) VisibleLineCount() int {
 	if !m.hasLines() {
 		return 0
@@ -152,9 +401,37 @@ func (m Model) VisibleLineCount() int {
 	return bottom - top + 1
 }
 
-// SetCurrentLine sets the current line.
+// TopOffset returns the stream offset of the line at the top of the viewport,
+// or of the last line, if the viewport is scrolled past it.
+// The offset counts the stream's bytes as read, before any decoding.
+// It returns false if there are no lines.
+func (m Model) TopOffset() (off int, ok bool) {
+	if !m.hasLines() {
+		return 0, false
+	}
+	n := clamp(m.CurrentLine, m.firstLine(), m.nlines()-1)
+	if m.hex() {
+		return n * hexRowSize, true
+	}
+	off, ok = m.buffer().LineOffset(m.bufferLine(n))
+	return m.rawOffset(off), ok
+}
+
+// TopLine returns the number of the line of the stream at the top of the viewport.
+// It differs from CurrentLine when showing a diff or a hex dump.
+func (m Model) TopLine() int {
+	if m.hex() {
+		off, _ := m.TopOffset()
+		line, _ := m.buffer().LineAt(m.textOffset(off))
+		return line
+	}
+	return m.bufferLine(m.CurrentLine)
+}
+
+// SetCurrentLine sets the current line, showing it from its first row.
 func (m *Model) SetCurrentLine(n int) {
-	m.CurrentLine = clamp(n, 0, m.maxLine())
+	m.CurrentLine = clamp(n, m.minLine(), m.maxLine())
+	m.CurrentSubline = 0
 }
 
 // ViewDown moves the view down by the number of lines in the viewport.
@@ -178,10 +455,16 @@ func (m *Model) HalfViewUp() {
 	m.LineUp(m.Height / 2)
 }
 
-// LineDown moves the view down by the given number of lines.
+// LineDown moves the view down by the given number of lines,
+// or of rows, when wrapping long lines.
 func (m *Model) LineDown(n int) (cmd tea.Cmd) {
-	next := min(m.CurrentLine+n, m.buffer.NLines()-1)
-	m.SetCurrentLine(next)
+	m.cancelJump()
+	if m.wrapping() {
+		m.rowsDown(n)
+	} else {
+		next := min(m.CurrentLine+n, m.nlines()-1)
+		m.SetCurrentLine(next)
+	}
 	if m.shouldReadMore() {
 		cmd = readCmd(m)
 	}
@@ -191,28 +474,170 @@ func (m *Model) LineDown(n int) (cmd tea.Cmd) {
 // LineUp moves the view down by the given number of lines. Returns the new
 // lines to show.
 func (m *Model) LineUp(n int) {
-	next := max(0, m.CurrentLine-n)
+	m.Follow = false
+	m.cancelJump()
+	if m.wrapping() {
+		m.rowsUp(n)
+		return
+	}
+	next := max(m.minLine(), m.CurrentLine-n)
 	m.SetCurrentLine(next)
 }
 
+// rowsDown moves the view down by n rows, stopping at the last row of the last line.
+func (m *Model) rowsDown(n int) {
+	width := m.wrapWidth()
+	line, sub := max(m.minLine(), m.CurrentLine), m.CurrentSubline
+	last := m.nlines() - 1
+	for n > 0 {
+		rows := m.rows(line, width)
+		if sub+n < rows {
+			sub += n
+			break
+		}
+		if line >= last {
+			sub = rows - 1
+			break
+		}
+		n -= rows - sub
+		line, sub = line+1, 0
+	}
+	m.CurrentLine, m.CurrentSubline = line, sub
+}
+
+// rowsUp moves the view up by n rows, stopping at the top.
+func (m *Model) rowsUp(n int) {
+	width := m.wrapWidth()
+	line, sub := m.CurrentLine, m.CurrentSubline
+	for n > sub && line > m.minLine() {
+		n -= sub + 1
+		line--
+		sub = m.rows(line, width) - 1
+	}
+	m.CurrentLine, m.CurrentSubline = line, max(0, sub-n)
+}
+
+// SetFollow sets whether to keep the end of the stream in view as it grows.
+func (m *Model) SetFollow(follow bool) tea.Cmd {
+	m.Follow = follow
+	if follow {
+		m.showEnd()
+	}
+	if m.shouldReadMore() {
+		return readCmd(m)
+	}
+	return nil
+}
+
+// showEnd scrolls so that the end of the stream is at the bottom of the viewport.
+func (m *Model) showEnd() {
+	_, height := m.contentSize()
+	last := m.nlines() - 1
+	if !m.wrapping() || last < 0 {
+		m.SetCurrentLine(max(m.minLine(), last-height+1))
+		return
+	}
+	width := m.wrapWidth()
+	line, rows := last, m.rows(last, width)
+	for line > m.minLine() && rows < height {
+		line--
+		rows += m.rows(line, width)
+	}
+	m.CurrentLine, m.CurrentSubline = max(line, m.minLine()), max(0, rows-height)
+}
+
+// ScrollRight scrolls the view right by n cells,
+// stopping once the end of the widest visible line is in view.
+func (m *Model) ScrollRight(n int) {
+	m.XOffset = max(0, min(m.XOffset+n, m.maxXOffset()))
+}
+
+// maxXOffset returns how far the view can usefully be scrolled to the right:
+// far enough to bring the end of the widest visible line into view.
+func (m Model) maxXOffset() int {
+	hex := m.hex()
+	width, _ := m.contentSize()
+	if !hex {
+		width = m.wrapWidth() // less any gutters
+	}
+	first, widest := m.firstLine(), 0
+	for _, i := range m.visibleNums() {
+		if i < first {
+			continue
+		}
+		var spans []span
+		if hex {
+			spans = m.hexRow(i)
+		} else {
+			spans = m.spans(i)
+		}
+		w := 0
+		for _, sp := range spans {
+			w += runewidth.StringWidth(sp.text)
+		}
+		widest = max(widest, w)
+	}
+	return widest - width
+}
+
+// ScrollLeft scrolls the view left by n cells, stopping at the left edge.
+func (m *Model) ScrollLeft(n int) {
+	m.XOffset = max(0, m.XOffset-n)
+}
+
+// ToggleWrap switches between wrapping and truncating long lines.
+func (m *Model) ToggleWrap() tea.Cmd {
+	return m.SetWrap(!m.Wrap)
+}
+
+// SetWrap sets whether to wrap long lines.
+func (m *Model) SetWrap(wrap bool) tea.Cmd {
+	m.Wrap = wrap
+	m.CurrentSubline = 0
+	if m.shouldReadMore() {
+		return readCmd(m)
+	}
+	return nil
+}
+
 // TotalLineCount returns the total number of lines (both hidden and visible) within the viewport.
 func (m Model) TotalLineCount() int {
-	return m.buffer.NLines()
+	return m.nlines()
 }
 
 func (m Model) hasLines() bool {
-	return m.buffer.NLines() > 0
+	return m.nlines() > 0
 }
 
 // GotoTop sets the viewport to the top position.
 func (m *Model) GotoTop() {
+	m.Follow = false
+	m.cancelJump()
 	m.SetCurrentLine(0)
 }
 
-// GotoBottom sets the viewport to the bottom position.
-func (m *Model) GotoBottom() {
-	m.SetCurrentLine(m.maxLine())
-	// return readCmd(m)
+// SetSep changes the record separator of the viewed stream.
+// Line numbers change, so it also scrolls back to the top.
+func (m *Model) SetSep(sep []byte) tea.Cmd {
+	m.buffer().SetSep(sep)
+	// Whether NULs look binary depends on the separator.
+	m.sniffed = false
+	m.sniff()
+	m.GotoTop()
+	// Line numbers have changed, so start the search and diff over.
+	cmd := m.Search(m.search.query)
+	if m.diff.base != nil {
+		cmd = tea.Batch(cmd, m.SetDiff(m.diff.base))
+	}
+	if m.shouldReadMore() {
+		cmd = tea.Batch(cmd, readCmd(m))
+	}
+	return cmd
+}
+
+// Sep returns the record separator of the viewed stream, or nil for newlines.
+func (m Model) Sep() []byte {
+	return m.buffer().Sep()
 }
 
 // Update handles standard message-based viewport updates.
@@ -225,11 +650,48 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			break
 		}
 		m.lastErr = msg.err
+		m.reading = false
+		m.sniff()
+		m.updateJump()
+		if m.Follow {
+			m.showEnd()
+		}
+		cmd = tea.Batch(m.searchMore(), m.diffMore())
 		if m.shouldReadMore() {
-			cmd = readCmd(&m)
+			cmd = tea.Batch(cmd, readCmd(&m))
 		}
 		// m.SetCurrentLine(clamp(m.CurrentLine, 0, m.maxLine()))
 
+	case changedMsg:
+		if msg.id != m.id {
+			break
+		}
+		// Re-rendering happens automatically. Keep watching until the stream ends.
+		m.sniff()
+		m.updateJump()
+		if m.Follow {
+			m.showEnd()
+		}
+		cmd = tea.Batch(m.searchMore(), m.diffMore())
+		if m.shared.Err() == nil {
+			cmd = tea.Batch(cmd, watchCmd(m.ctx, m.shared, changedMsg{id: m.id}))
+		}
+
+	case searchMsg:
+		cmd = m.updateSearch(msg)
+
+	case diffMsg:
+		cmd = m.updateDiff(msg)
+
+	case baseChangedMsg:
+		if msg.id != m.diff.id {
+			break
+		}
+		cmd = m.diffMore()
+		if m.diff.base.Err() == nil {
+			cmd = tea.Batch(cmd, watchCmd(m.ctx, m.diff.base, baseChangedMsg{id: m.diff.id}))
+		}
+
 	case tea.MouseMsg:
 		if !m.MouseWheelEnabled {
 			break
@@ -251,23 +713,37 @@ func (m Model) shouldReadMore() bool {
 		"Height", m.Height,
 		"VisibleLineCount", m.VisibleLineCount(),
 		"CurrentLine", m.CurrentLine,
-		"buffered lines", m.buffer.NLines(),
+		"buffered lines", m.buffer().NLines(),
 		"maxLine", m.maxLine(),
 		// "decision", x,
 	)
-	if m.VisibleLineCount() >= m.Height {
+	if m.reading {
+		// Another read will be along shortly.
+		return false
+	}
+	if m.Follow || m.jump.kind != jumpNone {
+		// Keep reading until the stream ends.
+		return m.lastErr == nil
+	}
+	if m.visibleRowCount() >= m.Height {
 		// Screen is full, and we're not at the bottom.
 		// We definitely don't need more data.
 		return false
 	}
-	if m.lastErr != nil /* && m.lastErr != io.EOF */ {
-		// There was an error reading the stream.
-		// Stop trying.
+	if m.lastErr != nil {
+		// The stream ended, successfully (io.EOF) or not.
+		// There will never be more data, so stop trying.
 		return false
 	}
 	return true
 }
 
+// Close stops the model from reading its stream.
+// Any read in progress returns promptly.
+func (m *Model) Close() {
+	m.cancel()
+}
+
 func (m *Model) Focus() {
 	m.focused = true
 }
@@ -276,27 +752,34 @@ func (m *Model) Blur() {
 	m.focused = false
 }
 
-// View renders the viewport into a string.
-func (m *Model) View() string {
-	w, h := m.Width, m.Height
-	if w <= 0 || h <= 0 {
-		return ""
-	}
-
-	style := m.Style
+// style returns the style for m's frame.
+func (m Model) style() lipgloss.Style {
 	if m.focused {
-		style = m.FocusStyle
+		return m.FocusStyle
 	}
+	return m.Style
+}
 
+// contentSize returns the size of the viewport inside its frame.
+func (m Model) contentSize() (width, height int) {
+	w, h := m.Width, m.Height
+	style := m.style()
 	if sw := style.GetWidth(); sw != 0 {
 		w = min(w, sw)
 	}
 	if sh := style.GetHeight(); sh != 0 {
 		h = min(h, sh)
 	}
+	return w - style.GetHorizontalFrameSize(), h - style.GetVerticalFrameSize()
+}
 
-	contentWidth := w - style.GetHorizontalFrameSize()
-	contentHeight := h - style.GetVerticalFrameSize()
+// View renders the viewport into a string.
+func (m *Model) View() string {
+	if m.Width <= 0 || m.Height <= 0 {
+		return ""
+	}
+	style := m.style()
+	contentWidth, contentHeight := m.contentSize()
 
 	contents := lipgloss.NewStyle().
 		Width(contentWidth).      // pad to width.
@@ -304,9 +787,21 @@ func (m *Model) View() string {
 		MaxHeight(contentHeight). // truncate height if taller.
 		MaxWidth(contentWidth).   // truncate width.
 		Render(strings.Join(m.visibleLines(), "\n"))
-	return style.Copy().
-		UnsetWidth().UnsetHeight(). // Style size already applied in contents.
-		Render(contents)
+	style = style.Copy().UnsetWidth().UnsetHeight() // Style size already applied in contents.
+	footer := statsText(m.shared.Stats())
+	if s := m.diffText(); s != "" {
+		footer += " · " + s
+	}
+	if s := m.jumpText(); s != "" {
+		footer += " · " + s
+	}
+	if s := m.searchText(); s != "" {
+		footer += " · " + s
+	}
+	if m.Follow {
+		footer += " · following"
+	}
+	return renderFrame(style, contents, m.Title, m.Status, footer)
 }
 
 func clamp(v, low, high int) int {
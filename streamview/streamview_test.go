@@ -0,0 +1,136 @@
+package streamview
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/josharian/pex/stream"
+)
+
+// newTestModel returns a model showing all of text, wrapped at width,
+// with no frame.
+func newTestModel(text string, width, height int) Model {
+	sh := stream.NewShared(strings.NewReader(text), stream.Options{})
+	io.ReadAll(sh.Reader())
+	m := New(sh)
+	m.Width, m.Height = width, height
+	m.Wrap = true
+	return m
+}
+
+type pos struct{ line, sub int }
+
+func TestRowsDown(t *testing.T) {
+	// Lines of 3, 1 and 2 rows.
+	m := newTestModel("abcdefghi\nj\nklmno\n", 3, 2)
+	for _, tt := range []struct {
+		from pos
+		n    int
+		want pos
+	}{
+		{pos{0, 0}, 1, pos{0, 1}},
+		{pos{0, 0}, 3, pos{1, 0}},
+		{pos{0, 2}, 2, pos{2, 0}},
+		{pos{0, 1}, 100, pos{2, 1}}, // stops at the last row
+		{pos{2, 1}, 1, pos{2, 1}},
+	} {
+		m.CurrentLine, m.CurrentSubline = tt.from.line, tt.from.sub
+		m.rowsDown(tt.n)
+		if got := (pos{m.CurrentLine, m.CurrentSubline}); got != tt.want {
+			t.Errorf("rowsDown(%d) from %v: got %v, want %v", tt.n, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestRowsUp(t *testing.T) {
+	m := newTestModel("abcdefghi\nj\nklmno\n", 3, 2)
+	for _, tt := range []struct {
+		from pos
+		n    int
+		want pos
+	}{
+		{pos{2, 1}, 1, pos{2, 0}},
+		{pos{2, 0}, 1, pos{1, 0}},
+		{pos{2, 0}, 2, pos{0, 2}},
+		{pos{1, 0}, 100, pos{0, 0}}, // stops at the top
+		{pos{0, 0}, 1, pos{0, 0}},
+	} {
+		m.CurrentLine, m.CurrentSubline = tt.from.line, tt.from.sub
+		m.rowsUp(tt.n)
+		if got := (pos{m.CurrentLine, m.CurrentSubline}); got != tt.want {
+			t.Errorf("rowsUp(%d) from %v: got %v, want %v", tt.n, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestShowEnd(t *testing.T) {
+	for _, tt := range []struct {
+		text   string
+		wrap   bool
+		height int
+		want   pos
+	}{
+		{"abcdefghi\nj\nklmno\n", true, 2, pos{2, 0}},
+		{"abcdefghi\nj\nklmno\n", true, 3, pos{1, 0}},
+		{"abcdefghi\nj\nklmno\n", true, 4, pos{0, 2}},
+		{"abcdefghi\nj\nklmno\n", true, 20, pos{0, 0}},
+		{"abcdefghi\nj\nklmno\n", false, 2, pos{1, 0}},
+		{"", true, 2, pos{0, 0}},
+	} {
+		m := newTestModel(tt.text, 3, tt.height)
+		m.Wrap = tt.wrap
+		m.showEnd()
+		if got := (pos{m.CurrentLine, m.CurrentSubline}); got != tt.want {
+			t.Errorf("showEnd with wrap %v and %d rows: got %v, want %v", tt.wrap, tt.height, got, tt.want)
+		}
+		if m.PastBottom() {
+			t.Errorf("showEnd with wrap %v and %d rows: past the bottom", tt.wrap, tt.height)
+		}
+	}
+}
+
+func TestPastBottom(t *testing.T) {
+	m := newTestModel("abcdefghi\nj\nklmno\n", 3, 2)
+	for _, tt := range []struct {
+		at   pos
+		want bool
+	}{
+		{pos{0, 2}, false},
+		{pos{2, 1}, false},
+		{pos{2, 2}, true}, // klmno has only 2 rows
+		{pos{3, 0}, true},
+	} {
+		m.CurrentLine, m.CurrentSubline = tt.at.line, tt.at.sub
+		if got := m.PastBottom(); got != tt.want {
+			t.Errorf("PastBottom at %v = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestScrollRight(t *testing.T) {
+	m := newTestModel("short\n"+strings.Repeat("x", 30)+"\n日本語\n", 10, 5)
+	m.Wrap = false
+	m.ScrollRight(5)
+	if m.XOffset != 5 {
+		t.Errorf("after scrolling right 5: got XOffset %d, want 5", m.XOffset)
+	}
+	// Stop once the end of the widest line is in view.
+	m.ScrollRight(100)
+	if m.XOffset != 20 {
+		t.Errorf("after scrolling right 105: got XOffset %d, want 20", m.XOffset)
+	}
+	// Line numbers take up some of the width.
+	m.LineNumbers = true
+	m.ScrollRight(100)
+	if m.XOffset != 22 {
+		t.Errorf("with line numbers: got XOffset %d, want 22", m.XOffset)
+	}
+	// Only visible lines count.
+	m.LineNumbers = false
+	m.CurrentLine = 2
+	m.ScrollRight(1)
+	if m.XOffset != 0 {
+		t.Errorf("with only short lines visible: got XOffset %d, want 0", m.XOffset)
+	}
+}